@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
 	"syscall"
 
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/internal/database/mongodb"
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/tac"
 	"github.com/adjivas/eir/pkg/factory"
 	"github.com/adjivas/eir/pkg/service"
 	logger_util "github.com/free5gc/util/logger"
+	"github.com/free5gc/util/mongoapi"
 	"github.com/free5gc/util/version"
 	"github.com/urfave/cli"
 )
@@ -43,6 +49,50 @@ func main() {
 			Name:  "log, l",
 			Usage: "Output NF log to `FILE`",
 		},
+		cli.StringSliceFlag{
+			Name:  "set, s",
+			Usage: "Override the configuration field of `KEY.PATH=VALUE`, over the EIR_* environment variables",
+		},
+		cli.BoolFlag{
+			Name:  "print-config",
+			Usage: "Print the effective configuration with its secrets redacted, then exit",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:   "import-tac",
+			Usage:  "Import a GSMA TAC database CSV/TSV export into the configured database",
+			Action: importTac,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config, c",
+					Usage: "Load configuration from `FILE`",
+				},
+				cli.StringSliceFlag{
+					Name:  "set, s",
+					Usage: "Override the configuration field of `KEY.PATH=VALUE`, over the EIR_* environment variables",
+				},
+				cli.StringFlag{
+					Name:  "file, f",
+					Usage: "Read the TAC database export from `FILE`",
+				},
+			},
+		},
+		{
+			Name:   "verify-audit",
+			Usage:  "Verify the hash chain of the audit records of the configured sink",
+			Action: verifyAudit,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config, c",
+					Usage: "Load configuration from `FILE`",
+				},
+				cli.StringSliceFlag{
+					Name:  "set, s",
+					Usage: "Override the configuration field of `KEY.PATH=VALUE`, over the EIR_* environment variables",
+				},
+			},
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		logger.MainLog.Errorf("EIR Run error: %v\n", err)
@@ -50,6 +100,10 @@ func main() {
 }
 
 func action(cliCtx *cli.Context) error {
+	if cliCtx.Bool("print-config") {
+		return printConfig(cliCtx)
+	}
+
 	tlsKeyLogPath, err := initLogFile(cliCtx.StringSlice("log"))
 	if err != nil {
 		return err
@@ -65,7 +119,8 @@ func action(cliCtx *cli.Context) error {
 		cancel()
 	}()
 
-	cfg, err := factory.ReadConfig(cliCtx.String("config"))
+	cfgPath, overrides := cliCtx.String("config"), cliCtx.StringSlice("set")
+	cfg, err := factory.ReadConfig(cfgPath, overrides...)
 	if err != nil {
 		return err
 	}
@@ -76,11 +131,131 @@ func action(cliCtx *cli.Context) error {
 	}
 	EIR = eir
 
+	// The SBI certificate is also reloaded on SIGHUP
+	sighupCh := make(chan os.Signal, 1)
+	signal.Notify(sighupCh, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(sighupCh)
+				return
+			case <-sighupCh:
+				logger.MainLog.Infoln("SIGHUP received, the configuration is reloaded")
+				if err := eir.ReloadConfig(cfgPath, overrides...); err != nil {
+					logger.MainLog.Errorf("The configuration isn't reloaded, the running one is kept: %+v", err)
+				}
+			}
+		}
+	}()
+
 	eir.Start()
 
 	return nil
 }
 
+func printConfig(cliCtx *cli.Context) error {
+	cfg, err := factory.ReadConfig(cliCtx.String("config"), cliCtx.StringSlice("set")...)
+	if err != nil {
+		return err
+	}
+	content, err := cfg.Redacted()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+func importTac(cliCtx *cli.Context) error {
+	path := cliCtx.String("file")
+	if path == "" {
+		return fmt.Errorf("the TAC database export file is missing")
+	}
+
+	cfg, err := factory.ReadConfig(cliCtx.String("config"), cliCtx.StringSlice("set")...)
+	if err != nil {
+		return err
+	}
+	factory.EirConfig = cfg
+
+	configuration := cfg.Configuration
+	switch configuration.DbConnectorType {
+	case database.DBCONNECTOR_TYPE_MEMORY:
+		return fmt.Errorf("the memory database isn't persisted, the TAC models would be lost")
+	case database.DBCONNECTOR_TYPE_MONGODB:
+		if err = mongoapi.SetMongoDB(configuration.Mongodb.Name, configuration.Mongodb.Url); err != nil {
+			return err
+		}
+		// The unique TAC index is created before the import, so each upsert finds its model by index
+		if err = mongodb.CreateIndexes(context.Background(), configuration.Mongodb.Name); err != nil {
+			return err
+		}
+	}
+	dbConnector := database.NewDbConnector(configuration.DbConnectorType)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.MainLog.Warnf("The TAC database export can't be closed: %+v", closeErr)
+		}
+	}()
+
+	imported, skipped, err := tac.Import(dbConnector, tac.ModelCollName, file)
+	if err != nil {
+		return err
+	}
+	logger.MainLog.Infof("%d TAC models are imported from %s, %d lines are skipped", imported, path, skipped)
+	return nil
+}
+
+func verifyAudit(cliCtx *cli.Context) error {
+	cfg, err := factory.ReadConfig(cliCtx.String("config"), cliCtx.StringSlice("set")...)
+	if err != nil {
+		return err
+	}
+	factory.EirConfig = cfg
+
+	configuration := cfg.Configuration
+	auditConfig := configuration.Audit
+	if auditConfig == nil {
+		return fmt.Errorf("the audit isn't configured")
+	}
+	var dbConnector database.DbConnector
+	switch auditConfig.Sink {
+	case factory.AuditSinkFile:
+		// The sink would create a missing file
+		if _, err = os.Stat(auditConfig.Path); err != nil {
+			return err
+		}
+	case factory.AuditSinkDatabase:
+		if configuration.DbConnectorType == database.DBCONNECTOR_TYPE_MEMORY {
+			return fmt.Errorf("the memory database isn't persisted, there is no audit record to verify")
+		}
+		if configuration.DbConnectorType == database.DBCONNECTOR_TYPE_MONGODB {
+			if err = mongoapi.SetMongoDB(configuration.Mongodb.Name, configuration.Mongodb.Url); err != nil {
+				return err
+			}
+		}
+		dbConnector = database.NewDbConnector(configuration.DbConnectorType)
+	}
+	sink, err := audit.NewSink(auditConfig, dbConnector)
+	if err != nil {
+		return err
+	}
+
+	verified, err := audit.Verify(sink)
+	if err != nil {
+		// The exit code reports the broken chain
+		return cli.NewExitError(fmt.Sprintf("The audit chain is broken after %d records: %v", verified, err), 1)
+	}
+	logger.MainLog.Infof("The audit chain of %d records is verified", verified)
+	return nil
+}
+
 func initLogFile(logNfPath []string) (string, error) {
 	logTlsKeyPath := ""
 
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/pkg/factory"
+)
+
+// The events of the audit records
+const (
+	EventCheck                  = "check"
+	EventCreateEquipmentStatus  = "create-equipment-status"
+	EventReplaceEquipmentStatus = "replace-equipment-status"
+	EventModifyEquipmentStatus  = "modify-equipment-status"
+	EventDeleteEquipmentStatus  = "delete-equipment-status"
+	EventReplaceTacRule         = "replace-tac-rule"
+	EventDeleteTacRule          = "delete-tac-rule"
+	EventDeleteClonedPei        = "delete-cloned-pei"
+	EventEscalateGreylisted     = "escalate-greylisted"
+	EventDetectClonedPei        = "detect-cloned-pei"
+)
+
+// The results which aren't an equipment status
+const (
+	// ResultDeleted is the result of the deletions
+	ResultDeleted = "DELETED"
+	// ResultCloned is the result of the clone flags stored by the clone detection
+	ResultCloned = "CLONED"
+)
+
+// Record is an audit record, it's chained to the previous one by its hash
+type Record struct {
+	Sequence     uint64    `json:"sequence"`
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	ConsumerNfId string    `json:"consumer_nf_id,omitempty"`
+	Pei          string    `json:"pei,omitempty"`
+	Supi         string    `json:"supi,omitempty"`
+	Gpsi         string    `json:"gpsi,omitempty"`
+	// Result is the equipment status returned or written, or the failure of the check
+	Result string `json:"result"`
+	// MatchedRule is the record, the TAC rule or the default which decided the result
+	MatchedRule string `json:"matched_rule,omitempty"`
+	// Model is the device model of the TAC of the PEI
+	Model        string `json:"model,omitempty"`
+	PreviousHash string `json:"previous_hash"`
+	Hash         string `json:"hash"`
+}
+
+// computeHash returns the SHA-256 of the record without its hash, the previous hash included
+func (r *Record) computeHash() (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+	content, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sink stores the audit records in order
+type Sink interface {
+	// Append writes the record after the last one
+	Append(record *Record) error
+	// Last returns the last record written, it's nil without record
+	Last() (*Record, error)
+	// Read calls the callback for each record in order until it returns an error
+	Read(callback func(record *Record) error) error
+}
+
+// NewSink returns the sink of the configuration, the database sink writes through the DbConnector
+func NewSink(config *factory.Audit, dbConnector database.DbConnector) (Sink, error) {
+	switch config.Sink {
+	case factory.AuditSinkFile:
+		return NewFileSink(config.Path, config.MaxSize)
+	case factory.AuditSinkDatabase:
+		return NewDbSink(dbConnector, CollName), nil
+	default:
+		return nil, fmt.Errorf("unsupported audit sink: %s", config.Sink)
+	}
+}
+
+// Auditor chains the records and writes them to its sink, a nil Auditor doesn't write anything.
+// Each record is chained to the previous one, so the records are written one at a time under the lock: the audited
+// requests are serialized by the write latency of the sink, a database sink bounds their throughput to one record
+// per round trip to the database.
+type Auditor struct {
+	mu   sync.Mutex
+	sink Sink
+	// last is read from the sink by the first record, the database may not be connected before
+	last   *Record
+	loaded bool
+}
+
+// NewAuditor returns the auditor continuing the chain of the sink
+func NewAuditor(sink Sink) *Auditor {
+	return &Auditor{sink: sink}
+}
+
+// Record chains the record to the last one and writes it
+func (a *Auditor) Record(record Record) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := a.append(&record)
+	if err != nil && a.loaded {
+		// Another replica may have appended to the sink, the record is chained again to its last record
+		a.loaded = false
+		err = a.append(&record)
+	}
+	return err
+}
+
+// append chains the record to the last one and writes it, the lock must be held
+func (a *Auditor) append(record *Record) error {
+	if !a.loaded {
+		last, err := a.sink.Last()
+		if err != nil {
+			return fmt.Errorf("the last audit record can't be read: %+v", err)
+		}
+		a.last, a.loaded = last, true
+	}
+	record.Sequence = 1
+	record.PreviousHash = ""
+	if a.last != nil {
+		record.Sequence = a.last.Sequence + 1
+		record.PreviousHash = a.last.Hash
+	}
+	record.Time = time.Now().UTC()
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	if err = a.sink.Append(record); err != nil {
+		return err
+	}
+	last := *record
+	a.last = &last
+	return nil
+}
+
+// Verify reads the records of the sink and checks their chain, it returns the count of the records verified
+func Verify(sink Sink) (uint64, error) {
+	var previous *Record
+	var count uint64
+	err := sink.Read(func(record *Record) error {
+		if err := verifyNext(previous, record); err != nil {
+			return err
+		}
+		previous = record
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// verifyNext checks that the record is the untampered successor of the previous one
+func verifyNext(previous *Record, record *Record) error {
+	sequence, previousHash := uint64(1), ""
+	if previous != nil {
+		sequence, previousHash = previous.Sequence+1, previous.Hash
+	}
+	if record.Sequence != sequence {
+		return fmt.Errorf("the audit record %d is found instead of the record %d", record.Sequence, sequence)
+	}
+	if record.PreviousHash != previousHash {
+		return fmt.Errorf("the audit record %d isn't chained to the previous record", record.Sequence)
+	}
+	hash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+	if record.Hash != hash {
+		return fmt.Errorf("the audit record %d is tampered, its hash doesn't match its content", record.Sequence)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adjivas/eir/internal/database/memory"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func recordChecks(t *testing.T, auditor *Auditor, peis ...string) {
+	for _, pei := range peis {
+		require.Nil(t, auditor.Record(Record{
+			Event:        EventCheck,
+			ConsumerNfId: "8ff6c8f0-1c1a-4c2e-9a4e-1c3f5e2b7d10",
+			Pei:          pei,
+			Result:       factory.EquipmentStatusWhitelisted,
+			MatchedRule:  "default-status",
+		}))
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(path, factory.AuditDefaultMaxSize)
+	require.Nil(t, err)
+	recordChecks(t, NewAuditor(sink), "imei-012345678901237", "imei-490154203237518")
+	require.Nil(t, sink.Close())
+
+	// The chain resumes from the last record of the file
+	sink, err = NewFileSink(path, factory.AuditDefaultMaxSize)
+	require.Nil(t, err)
+	defer sink.Close()
+	recordChecks(t, NewAuditor(sink), "imei-353456789012347")
+
+	verified, err := Verify(sink)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+
+	last, err := sink.Last()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), last.Sequence)
+	assert.Equal(t, "imei-353456789012347", last.Pei)
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Each file holds a single record
+	sink, err := NewFileSink(path, 1)
+	require.Nil(t, err)
+	defer sink.Close()
+	recordChecks(t, NewAuditor(sink), "imei-012345678901237", "imei-490154203237518", "imei-353456789012347")
+
+	files, err := sink.files()
+	require.Nil(t, err)
+	assert.Len(t, files, 3)
+
+	verified, err := Verify(sink)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+}
+
+func TestFileSinkTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+		err    string
+	}{
+		{
+			name: "Modified",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], "WHITELISTED", "BLACKLISTED", 1)
+				return lines
+			},
+			err: "the audit record 2 is tampered, its hash doesn't match its content",
+		},
+		{
+			name: "Removed",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			err: "the audit record 3 is found instead of the record 2",
+		},
+		{
+			name: "Truncated",
+			tamper: func(lines []string) []string {
+				return lines[1:]
+			},
+			err: "the audit record 2 is found instead of the record 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			sink, err := NewFileSink(path, factory.AuditDefaultMaxSize)
+			require.Nil(t, err)
+			defer sink.Close()
+			recordChecks(t, NewAuditor(sink), "imei-012345678901237", "imei-490154203237518", "imei-353456789012347")
+
+			content, err := os.ReadFile(path)
+			require.Nil(t, err)
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(content)), "\n"))
+			require.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+			_, err = Verify(sink)
+			require.NotNil(t, err)
+			assert.Equal(t, tt.err, err.Error())
+		})
+	}
+}
+
+func TestDbSink(t *testing.T) {
+	db, err := memory.NewMemoryDbConnector(&factory.Memory{})
+	require.Nil(t, err)
+	sink := NewDbSink(db, CollName)
+	recordChecks(t, NewAuditor(sink), "imei-012345678901237", "imei-490154203237518")
+	// The chain resumes from the last record of the collection
+	recordChecks(t, NewAuditor(sink), "imei-353456789012347")
+
+	verified, err := Verify(sink)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+
+	documents, errDatabase := db.GetManyDataFromDB(CollName, nil)
+	require.Nil(t, errDatabase)
+	assert.Len(t, documents, 3)
+}
+
+func TestDbSinkReplicas(t *testing.T) {
+	db, err := memory.NewMemoryDbConnector(&factory.Memory{})
+	require.Nil(t, err)
+	sink := NewDbSink(db, CollName)
+	first, second := NewAuditor(sink), NewAuditor(sink)
+	recordChecks(t, first, "imei-012345678901237")
+	recordChecks(t, second, "imei-490154203237518")
+	// The first replica is behind the sink, its record is chained again to the last one
+	recordChecks(t, first, "imei-353456789012347")
+
+	verified, err := Verify(sink)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+}
+
+func TestDbSinkOrder(t *testing.T) {
+	db, err := memory.NewMemoryDbConnector(&factory.Memory{})
+	require.Nil(t, err)
+	recordChecks(t, NewAuditor(NewDbSink(db, CollName)),
+		"imei-012345678901237", "imei-490154203237518", "imei-353456789012347")
+	documents, errDatabase := db.GetManyDataFromDB(CollName, nil)
+	require.Nil(t, errDatabase)
+
+	// The records written in another order are read in the order of their sequence
+	reversed, err := memory.NewMemoryDbConnector(&factory.Memory{})
+	require.Nil(t, err)
+	for i := len(documents) - 1; i >= 0; i-- {
+		_, errDatabase = reversed.PostDataToDB(CollName, bson.M{"sequence": documents[i]["sequence"]}, documents[i])
+		require.Nil(t, errDatabase)
+	}
+	verified, err := Verify(NewDbSink(reversed, CollName))
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+}
+
+func TestNilAuditor(t *testing.T) {
+	var auditor *Auditor
+	assert.Nil(t, auditor.Record(Record{Event: EventCheck}))
+}
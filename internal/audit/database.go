@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adjivas/eir/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CollName is the collection of the audit records
+const CollName = "policyData.ues.eirAuditRecords"
+
+// DbSink writes the records into a collection of the DbConnector
+type DbSink struct {
+	db       database.DbConnector
+	collName string
+}
+
+func NewDbSink(db database.DbConnector, collName string) *DbSink {
+	return &DbSink{db: db, collName: collName}
+}
+
+func recordFromMap(data map[string]interface{}) (*Record, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err = json.Unmarshal(content, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (s *DbSink) Append(record *Record) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(content, &data); err != nil {
+		return err
+	}
+	// The sequence is unique in the collection, a replica appending the same sequence is rejected
+	data["sequence"] = int64(record.Sequence)
+
+	existed, errDatabase := s.db.PostDataToDB(s.collName, bson.M{"sequence": int64(record.Sequence)}, data)
+	if errDatabase != nil {
+		return fmt.Errorf("the audit record %d can't be written: %s", record.Sequence, errDatabase.Detail)
+	}
+	if existed {
+		return fmt.Errorf("the audit record %d already exists", record.Sequence)
+	}
+	return nil
+}
+
+func (s *DbSink) Last() (*Record, error) {
+	data, errDatabase := s.db.GetLastDataFromDB(s.collName, "sequence")
+	if errDatabase != nil {
+		if errDatabase.Status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the last audit record can't be read: %s", errDatabase.Detail)
+	}
+	return recordFromMap(data)
+}
+
+// Read iterates the records in the order of their sequence, the chain is verified while the database streams them
+func (s *DbSink) Read(callback func(record *Record) error) error {
+	var errCallback error
+	errDatabase := s.db.IterateDataFromDB(s.collName, bson.M{}, "sequence", func(data map[string]interface{}) error {
+		record, err := recordFromMap(data)
+		if err == nil {
+			err = callback(record)
+		}
+		errCallback = err
+		return err
+	})
+	if errCallback != nil {
+		return errCallback
+	}
+	if errDatabase != nil {
+		return fmt.Errorf("the audit records can't be read: %s", errDatabase.Detail)
+	}
+	return nil
+}
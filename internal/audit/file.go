@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotationLayout suffixes the rotated files, their names sort in the order of the chain
+const rotationLayout = "20060102T150405.000000000"
+
+// FileSink writes the records as JSON lines, the file is rotated once it reaches its maximum size
+type FileSink struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewFileSink(path string, maxSize int64) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSize}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	rotated := s.path + "." + time.Now().UTC().Format(rotationLayout)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *FileSink) Append(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size > 0 && s.maxSize > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err = s.rotate(); err != nil {
+			return fmt.Errorf("the audit file can't be rotated: %+v", err)
+		}
+	}
+	written, err := s.file.Write(line)
+	s.size += int64(written)
+	return err
+}
+
+// files returns the rotated files then the current one, in the order of the chain
+func (s *FileSink) files() ([]string, error) {
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, match := range matches {
+		if _, err = time.Parse(rotationLayout, strings.TrimPrefix(match, s.path+".")); err == nil {
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return append(files, s.path), nil
+}
+
+func readFile(path string, callback func(record *Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("the line %d of [%s] is malformed: %+v", line, path, err)
+		}
+		if err = callback(&record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *FileSink) Last() (*Record, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	// The current file is empty just after a rotation
+	for i := len(files) - 1; i >= 0; i-- {
+		var last *Record
+		if err = readFile(files[i], func(record *Record) error {
+			last = record
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *FileSink) Read(callback func(record *Record) error) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if err = readFile(path, callback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,134 @@
+package context
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/free5gc/openapi/models"
+)
+
+// AmfPeer is an AMF instance known from the NRF, it's allowed to query the EIR
+type AmfPeer struct {
+	NfInstanceId  string
+	Fqdn          string
+	Ipv4Addresses []string
+	Ipv6Addresses []string
+	PlmnList      []models.PlmnId
+}
+
+// Identities returns the identities of the AMF which can be matched against its client certificate
+func (p AmfPeer) Identities() []string {
+	identities := []string{p.NfInstanceId}
+	if p.Fqdn != "" {
+		identities = append(identities, p.Fqdn)
+	}
+	return identities
+}
+
+// AmfPeerFromNfProfile returns the AMF peer of a NF profile notified by the NRF
+func AmfPeerFromNfProfile(profile *models.NrfNfManagementNfProfile) AmfPeer {
+	return AmfPeer{
+		NfInstanceId:  profile.NfInstanceId,
+		Fqdn:          profile.Fqdn,
+		Ipv4Addresses: profile.Ipv4Addresses,
+		Ipv6Addresses: profile.Ipv6Addresses,
+		PlmnList:      profile.PlmnList,
+	}
+}
+
+// AmfPeerFromDiscovery returns the AMF peer of a NF profile discovered from the NRF
+func AmfPeerFromDiscovery(profile *models.NrfNfDiscoveryNfProfile) AmfPeer {
+	return AmfPeer{
+		NfInstanceId:  profile.NfInstanceId,
+		Fqdn:          profile.Fqdn,
+		Ipv4Addresses: profile.Ipv4Addresses,
+		Ipv6Addresses: profile.Ipv6Addresses,
+		PlmnList:      profile.PlmnList,
+	}
+}
+
+// amfPeers is the live registry of the AMF peers, by NF instance ID
+type amfPeers struct {
+	mu    sync.RWMutex
+	peers map[string]AmfPeer
+	// notificationId is the last segment of the notification URI of the subscription, only the NRF knows it
+	notificationId string
+}
+
+// SetAmfNotificationId sets the notification ID of the subscription to the AMF status
+func (c *EIRContext) SetAmfNotificationId(notificationId string) {
+	c.amfPeers.mu.Lock()
+	defer c.amfPeers.mu.Unlock()
+	c.amfPeers.notificationId = notificationId
+}
+
+// IsAmfNotificationId reports whether the notification ID is the one of the subscription to the AMF status
+func (c *EIRContext) IsAmfNotificationId(notificationId string) bool {
+	c.amfPeers.mu.RLock()
+	defer c.amfPeers.mu.RUnlock()
+	return c.amfPeers.notificationId != "" && c.amfPeers.notificationId == notificationId
+}
+
+// ReplaceAmfPeers replaces the AMF peers by the discovered ones
+func (c *EIRContext) ReplaceAmfPeers(peers []AmfPeer) {
+	c.amfPeers.mu.Lock()
+	defer c.amfPeers.mu.Unlock()
+	c.amfPeers.peers = make(map[string]AmfPeer, len(peers))
+	for _, peer := range peers {
+		c.amfPeers.peers[peer.NfInstanceId] = peer
+	}
+}
+
+// SetAmfPeer adds or updates an AMF peer
+func (c *EIRContext) SetAmfPeer(peer AmfPeer) {
+	c.amfPeers.mu.Lock()
+	defer c.amfPeers.mu.Unlock()
+	if c.amfPeers.peers == nil {
+		c.amfPeers.peers = make(map[string]AmfPeer)
+	}
+	c.amfPeers.peers[peer.NfInstanceId] = peer
+}
+
+// RemoveAmfPeer removes an AMF peer, it returns whether the AMF was known
+func (c *EIRContext) RemoveAmfPeer(nfInstanceId string) bool {
+	c.amfPeers.mu.Lock()
+	defer c.amfPeers.mu.Unlock()
+	_, ok := c.amfPeers.peers[nfInstanceId]
+	delete(c.amfPeers.peers, nfInstanceId)
+	return ok
+}
+
+// AmfPeer returns the AMF peer of the NF instance ID
+func (c *EIRContext) AmfPeer(nfInstanceId string) (AmfPeer, bool) {
+	c.amfPeers.mu.RLock()
+	defer c.amfPeers.mu.RUnlock()
+	peer, ok := c.amfPeers.peers[nfInstanceId]
+	return peer, ok
+}
+
+// AmfPeers returns the AMF peers sorted by NF instance ID
+func (c *EIRContext) AmfPeers() []AmfPeer {
+	c.amfPeers.mu.RLock()
+	defer c.amfPeers.mu.RUnlock()
+	peers := make([]AmfPeer, 0, len(c.amfPeers.peers))
+	for _, peer := range c.amfPeers.peers {
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NfInstanceId < peers[j].NfInstanceId })
+	return peers
+}
+
+// IsAmfPeer reports whether one of the identities is the NF instance ID or the FQDN of an AMF peer
+func (c *EIRContext) IsAmfPeer(identities []string) bool {
+	c.amfPeers.mu.RLock()
+	defer c.amfPeers.mu.RUnlock()
+	for _, peer := range c.amfPeers.peers {
+		for _, identity := range peer.Identities() {
+			if slices.Contains(identities, identity) {
+				return true
+			}
+		}
+	}
+	return false
+}
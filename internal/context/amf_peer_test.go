@@ -0,0 +1,37 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmfPeers(t *testing.T) {
+	c := &EIRContext{}
+	c.SetAmfPeer(AmfPeer{NfInstanceId: "amf-2"})
+	c.ReplaceAmfPeers([]AmfPeer{{NfInstanceId: "amf-3"}, {NfInstanceId: "amf-1", Fqdn: "amf1.5gc"}})
+	c.SetAmfPeer(AmfPeer{NfInstanceId: "amf-4"})
+
+	assert.Equal(t, []AmfPeer{{NfInstanceId: "amf-1", Fqdn: "amf1.5gc"}, {NfInstanceId: "amf-3"}, {NfInstanceId: "amf-4"}},
+		c.AmfPeers())
+
+	peer, ok := c.AmfPeer("amf-1")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"amf-1", "amf1.5gc"}, peer.Identities())
+	assert.True(t, c.IsAmfPeer([]string{"operator", "amf1.5gc"}))
+	assert.False(t, c.IsAmfPeer([]string{"operator"}))
+
+	assert.True(t, c.RemoveAmfPeer("amf-3"))
+	assert.False(t, c.RemoveAmfPeer("amf-3"))
+	_, ok = c.AmfPeer("amf-3")
+	assert.False(t, ok)
+}
+
+func TestAmfNotificationId(t *testing.T) {
+	c := &EIRContext{}
+	assert.False(t, c.IsAmfNotificationId(""))
+
+	c.SetAmfNotificationId("7e0c5b52-2f6c-4c4d-9a0e-000000000001")
+	assert.True(t, c.IsAmfNotificationId("7e0c5b52-2f6c-4c4d-9a0e-000000000001"))
+	assert.False(t, c.IsAmfNotificationId("7e0c5b52-2f6c-4c4d-9a0e-000000000002"))
+}
@@ -2,17 +2,23 @@ package context
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/metrics"
 	"github.com/adjivas/eir/pkg/factory"
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/openapi/oauth"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -21,11 +27,13 @@ var eirContext = EIRContext{}
 func Init() {
 	eirContext.Name = "eir"
 
+	// The provisioning service is registered so the NRF grants its scope to the access tokens
 	serviceName := []models.ServiceName{
 		models.ServiceName_N5G_EIR_EIC,
+		models.ServiceName(factory.EirProvServiceName),
 	}
 
-	eirContext.NrfUri = GetIPUri()
+	eirContext.UseNrf(GetIPUri())
 	initEirContext()
 
 	config := factory.EirConfig
@@ -33,19 +41,48 @@ func Init() {
 }
 
 type EIRContext struct {
-	Name            string
-	UriScheme       models.UriScheme
-	RegisterIP      netip.Addr // IP register to NRF
-	BindingIP       netip.Addr
-	SBIPort         int
-	DefaultStatus   string
-	NfService       map[models.ServiceName]models.NrfNfManagementNfService
-	NfId            string
-	NrfUri          string
-	NrfCertPem      string
-	OAuth2Required  bool
+	Name       string
+	UriScheme  models.UriScheme
+	RegisterIP netip.Addr // IP register to NRF
+	BindingIP  netip.Addr
+	SBIPort    int
+	NfService  map[models.ServiceName]models.NrfNfManagementNfService
+	// nfId is the NF instance ID, it's replaced by the registration to the NRF
+	nfId string
+	// nrfUri and nrfCertPem are the ones of the NRF in use, they're swapped by the heartbeat on failover
+	nrfUri     string
+	nrfCertPem string
+	// nrfMu guards the state of the registration to the NRF, written by the heartbeat and read by the requests
+	nrfMu sync.RWMutex
+	// Nrfs are tried in order
+	Nrfs []factory.Nrf
+	// oauth2Required is received from the NRF by the registration and read by each request
+	oauth2Required atomic.Bool
+	// PlmnList, SNssais, Locality, Capacity, Priority and Fqdn are registered in the NF profile
+	PlmnList []models.PlmnId
+	SNssais  []models.ExtSnssai
+	Locality string
+	Capacity int32
+	Priority int32
+	Fqdn     string
+	// heartBeatTimer is the period of the NF heartbeats returned by the NRF
+	heartBeatTimer time.Duration
+	// tokenVerifier verifies the access tokens locally instead of the NRF certificate, it's swapped on reload
+	tokenVerifier   TokenVerifier
+	tokenVerifierMu sync.RWMutex
+	// registered is the readiness of the EIR, it's registered to the NRF
+	registered atomic.Bool
+	// amfPeers are the AMF instances discovered from the NRF and kept up to date by its notifications
+	amfPeers amfPeers
+	// amfSubscriptionId is the NRF subscription to the status of the AMF instances
+	amfSubscriptionId string
+	// amfSubscriptionValidity is the validity time of the subscription, it's zero when it doesn't expire
+	amfSubscriptionValidity time.Time
 }
 
+// ErrInsufficientScope is returned when the access token is valid but isn't granted the scope of the service
+var ErrInsufficientScope = errors.New("insufficient scope")
+
 type NFContext interface {
 	AuthorizationCheck(token string, serviceName models.ServiceName) error
 }
@@ -57,30 +94,37 @@ func initEirContext() {
 	logger.UtilLog.Infof("eirconfig Info: Version[%s] Description[%s]", config.Info.Version, config.Info.Description)
 
 	configuration := config.Configuration
-	eirContext.NfId = uuid.New().String()
+	eirContext.SetNfId(uuid.New().String())
 	sbi := configuration.Sbi
 
 	eirContext.SBIPort = sbi.Port                       // default port
 	eirContext.UriScheme = models.UriScheme(sbi.Scheme) // default localhost
 
-	if bindingIP := os.Getenv(sbi.BindingIP); bindingIP != "" {
-		logger.UtilLog.Info("Parsing BindingIP address from ENV Variable.")
-		sbi.BindingIP = bindingIP
-	}
-	if registerIP := os.Getenv(sbi.RegisterIP); registerIP != "" {
-		logger.UtilLog.Info("Parsing RegisterIP address from ENV Variable.")
-		sbi.RegisterIP = registerIP
-	}
-
 	eirContext.BindingIP = resolveIP(sbi.BindingIP)
 	eirContext.RegisterIP = resolveIP(sbi.RegisterIP)
 
-	eirContext.NrfUri = configuration.NrfUri
-	eirContext.NrfCertPem = configuration.NrfCertPem
+	eirContext.Nrfs = nil
+	for _, nrf := range configuration.Nrfs {
+		eirContext.Nrfs = append(eirContext.Nrfs, *nrf)
+	}
+	eirContext.nrfMu.Lock()
+	eirContext.nrfUri = configuration.NrfUri
+	eirContext.nrfCertPem = configuration.NrfCertPem
+	eirContext.nrfMu.Unlock()
 
-	if defaultStatus := configuration.DefaultStatus; defaultStatus != "" {
-		eirContext.DefaultStatus = defaultStatus
+	// Without its key set, the EIR would accept the tokens the local verification is configured to refuse
+	tokenVerifier, err := NewTokenVerifier(configuration.OAuth2, eirContext.NfId())
+	if err != nil {
+		logger.InitLog.Fatalf("The OAuth2 verification can't be initialized: %+v", err)
 	}
+	eirContext.SetTokenVerifier(tokenVerifier)
+
+	eirContext.PlmnList = configuration.PlmnList
+	eirContext.SNssais = configuration.SNssais
+	eirContext.Locality = configuration.Locality
+	eirContext.Capacity = int32(configuration.Capacity)
+	eirContext.Priority = int32(configuration.Priority)
+	eirContext.Fqdn = configuration.Fqdn
 
 	fmt.Println("eir context = ", &eirContext)
 }
@@ -116,6 +160,10 @@ func initNfService(serviceName []models.ServiceName, version string) (
 			NfServiceStatus: models.NfServiceStatus_REGISTERED,
 			ApiPrefix:       GetIPUri(),
 			IpEndPoints:     GetIpEndPoint(),
+			Fqdn:            eirContext.Fqdn,
+			SNssais:         eirContext.SNssais,
+			Capacity:        eirContext.Capacity,
+			Priority:        eirContext.Priority,
 		}
 	}
 
@@ -150,27 +198,186 @@ func GetIpEndPoint() []models.IpEndPoint {
 	return nil
 }
 
+// UseNrf makes the NRF of the URI the one in use, with its certificate
+func (c *EIRContext) UseNrf(uri string) {
+	c.nrfMu.Lock()
+	defer c.nrfMu.Unlock()
+	c.nrfUri = uri
+	for _, nrf := range c.Nrfs {
+		if nrf.Uri == uri {
+			c.nrfCertPem = nrf.CertPem
+			return
+		}
+	}
+}
+
+// NfId returns the NF instance ID
+func (c *EIRContext) NfId() string {
+	c.nrfMu.RLock()
+	defer c.nrfMu.RUnlock()
+	return c.nfId
+}
+
+// SetNfId sets the NF instance ID
+func (c *EIRContext) SetNfId(nfId string) {
+	c.nrfMu.Lock()
+	defer c.nrfMu.Unlock()
+	c.nfId = nfId
+}
+
+// HeartBeatTimer returns the period of the NF heartbeats, it's zero before the registration
+func (c *EIRContext) HeartBeatTimer() time.Duration {
+	c.nrfMu.RLock()
+	defer c.nrfMu.RUnlock()
+	return c.heartBeatTimer
+}
+
+// SetHeartBeatTimer sets the period of the NF heartbeats returned by the NRF
+func (c *EIRContext) SetHeartBeatTimer(heartBeatTimer time.Duration) {
+	c.nrfMu.Lock()
+	defer c.nrfMu.Unlock()
+	c.heartBeatTimer = heartBeatTimer
+}
+
+// AmfSubscription returns the NRF subscription to the AMF status and its validity time, the ID is empty without one
+func (c *EIRContext) AmfSubscription() (string, time.Time) {
+	c.nrfMu.RLock()
+	defer c.nrfMu.RUnlock()
+	return c.amfSubscriptionId, c.amfSubscriptionValidity
+}
+
+// SetAmfSubscription sets the NRF subscription to the AMF status, an empty ID and a zero validity time remove it
+func (c *EIRContext) SetAmfSubscription(subscriptionId string, validityTime time.Time) {
+	c.nrfMu.Lock()
+	defer c.nrfMu.Unlock()
+	c.amfSubscriptionId = subscriptionId
+	c.amfSubscriptionValidity = validityTime
+}
+
+// NrfUri returns the URI of the NRF in use
+func (c *EIRContext) NrfUri() string {
+	c.nrfMu.RLock()
+	defer c.nrfMu.RUnlock()
+	return c.nrfUri
+}
+
+// NrfCertPem returns the certificate of the NRF in use
+func (c *EIRContext) NrfCertPem() string {
+	c.nrfMu.RLock()
+	defer c.nrfMu.RUnlock()
+	return c.nrfCertPem
+}
+
+// SetRegistered records whether the EIR is registered to the NRF
+func (c *EIRContext) SetRegistered(registered bool) {
+	c.registered.Store(registered)
+	if registered {
+		metrics.NrfRegistered.Set(1)
+	} else {
+		metrics.NrfRegistered.Set(0)
+	}
+}
+
+// IsRegistered reports whether the EIR is registered to the NRF
+func (c *EIRContext) IsRegistered() bool {
+	return c.registered.Load()
+}
+
+// NewTokenVerifier returns the verifier of the tokens granted to the EIR, it's nil without OAuth2 configuration
+func NewTokenVerifier(oauth2 *factory.OAuth2, nfId string) (TokenVerifier, error) {
+	if oauth2 == nil {
+		return nil, nil
+	}
+	audiences := []string{string(models.NrfNfManagementNfType__5_G_EIR), nfId}
+	verifier, err := NewJwksVerifier(oauth2, audiences)
+	if err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+func (c *EIRContext) GetTokenVerifier() TokenVerifier {
+	c.tokenVerifierMu.RLock()
+	defer c.tokenVerifierMu.RUnlock()
+	return c.tokenVerifier
+}
+
+func (c *EIRContext) SetTokenVerifier(tokenVerifier TokenVerifier) {
+	c.tokenVerifierMu.Lock()
+	defer c.tokenVerifierMu.Unlock()
+	c.tokenVerifier = tokenVerifier
+}
+
 // Create new EIR context
 func GetSelf() *EIRContext {
 	return &eirContext
 }
 
+// SetOAuth2Required records whether the NRF requires the access tokens
+func (c *EIRContext) SetOAuth2Required(required bool) {
+	c.oauth2Required.Store(required)
+}
+
+// IsOAuth2Required reports whether the NRF requires the access tokens
+func (c *EIRContext) IsOAuth2Required() bool {
+	return c.oauth2Required.Load()
+}
+
 func (c *EIRContext) GetTokenCtx(serviceName models.ServiceName, targetNF models.NrfNfManagementNfType) (
 	context.Context, *models.ProblemDetails, error,
 ) {
-	if !c.OAuth2Required {
+	return c.GetTokenCtxFromNrf(c.NrfUri(), serviceName, targetNF)
+}
+
+// GetTokenCtxFromNrf requests the access token to the NRF of the URI, which isn't always the one in use on failover
+func (c *EIRContext) GetTokenCtxFromNrf(nrfUri string, serviceName models.ServiceName,
+	targetNF models.NrfNfManagementNfType,
+) (context.Context, *models.ProblemDetails, error) {
+	if !c.IsOAuth2Required() {
 		return context.TODO(), nil, nil
 	}
 	return oauth.GetTokenCtx(models.NrfNfManagementNfType__5_G_EIR, targetNF,
-		c.NfId, c.NrfUri, string(serviceName))
+		c.NfId(), nrfUri, string(serviceName))
 }
 
 func (c *EIRContext) AuthorizationCheck(token string, serviceName models.ServiceName) error {
-	if !c.OAuth2Required {
+	tokenVerifier := c.GetTokenVerifier()
+	if !c.IsOAuth2Required() && tokenVerifier == nil {
 		logger.UtilLog.Debugf("EIRContext::AuthorizationCheck: OAuth2 not required\n")
 		return nil
 	}
 
 	logger.UtilLog.Debugf("EIRContext::AuthorizationCheck: token[%s] serviceName[%s]\n", token, serviceName)
-	return oauth.VerifyOAuth(token, string(serviceName), c.NrfCertPem)
+	if token == "" {
+		return errors.New("the access token is missing")
+	}
+	if tokenVerifier != nil {
+		return tokenVerifier.Verify(token, serviceName)
+	}
+	// The OAuth library reports a scope mismatch like any other failure, so the token is verified with its own
+	// scope and the service is then looked up in it
+	scope, err := tokenScope(token)
+	if err != nil {
+		return err
+	}
+	if err = oauth.VerifyOAuth(token, scope, c.NrfCertPem()); err != nil {
+		return err
+	}
+	if !slices.Contains(strings.Fields(scope), string(serviceName)) {
+		return fmt.Errorf("%w: the token scope [%s] doesn't grant %s", ErrInsufficientScope, scope, serviceName)
+	}
+	return nil
+}
+
+// tokenScope returns the scope claimed by the bearer token, its signature isn't verified
+func tokenScope(token string) (string, error) {
+	bearer, found := strings.CutPrefix(token, "Bearer ")
+	if !found {
+		return "", errors.New("the authorization isn't a bearer token")
+	}
+	var claims tokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(bearer, &claims); err != nil {
+		return "", fmt.Errorf("the access token is malformed: %+v", err)
+	}
+	return claims.Scope, nil
 }
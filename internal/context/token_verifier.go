@@ -0,0 +1,252 @@
+package context
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenVerifier verifies the access token of a request to a service
+type TokenVerifier interface {
+	Verify(token string, serviceName models.ServiceName) error
+}
+
+// tokenClaims are the claims of the access tokens issued by the NRF
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope  string `json:"scope"`  // space separated service names
+	NfType string `json:"nfType"` // NF type of the consumer
+}
+
+// JwksVerifier verifies the access tokens locally against a JSON Web Key Set, the JWKS file is reloaded
+// when it's modified so the keys are rotated without restarting
+type JwksVerifier struct {
+	path      string
+	audiences []string
+	// nfTypes are the NF types allowed by service, a service without NF types allows any of them
+	nfTypes map[models.ServiceName][]string
+
+	mu      sync.RWMutex
+	modTime time.Time
+	keys    map[string]crypto.PublicKey
+}
+
+var _ TokenVerifier = &JwksVerifier{}
+
+// NewJwksVerifier returns a verifier of the tokens granted to one of the audiences, or the error of a malformed
+// inline key set or of a key set file which can't be loaded. Once created, a key set file which can't be reloaded
+// is logged and the previous keys are kept.
+func NewJwksVerifier(config *factory.OAuth2, audiences []string) (*JwksVerifier, error) {
+	v := &JwksVerifier{
+		path:      config.JwksPath,
+		audiences: audiences,
+		nfTypes: map[models.ServiceName][]string{
+			models.ServiceName_N5G_EIR_EIC:                 config.AllowedNfTypes,
+			models.ServiceName(factory.EirProvServiceName): config.ProvisioningNfTypes,
+		},
+	}
+	if len(config.Audience) > 0 {
+		v.audiences = config.Audience
+	}
+
+	if config.Jwks != "" {
+		keys, err := ParseJwks([]byte(config.Jwks))
+		if err != nil {
+			return nil, fmt.Errorf("the inline JWKS is malformed: %w", err)
+		}
+		v.keys = keys
+	} else if err := v.reload(); err != nil {
+		return nil, fmt.Errorf("the JWKS [%s] can't be loaded: %w", v.path, err)
+	}
+	return v, nil
+}
+
+// reload reads the JWKS file again when it was modified since its last read
+func (v *JwksVerifier) reload() error {
+	if v.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return err
+	}
+	v.mu.RLock()
+	modified := !info.ModTime().Equal(v.modTime)
+	v.mu.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	content, err := os.ReadFile(v.path)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJwks(content)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.modTime = info.ModTime()
+	v.mu.Unlock()
+	logger.UtilLog.Infof("The JWKS [%s] is loaded with %d keys", v.path, len(keys))
+	return nil
+}
+
+func (v *JwksVerifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	// A token without kid is accepted when the key set has a single key
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("the key [%s] isn't in the JWKS", kid)
+}
+
+// Verify checks the signature, the expiration, the audience, the scope and the NF type of the bearer token
+func (v *JwksVerifier) Verify(token string, serviceName models.ServiceName) error {
+	bearer, found := strings.CutPrefix(token, "Bearer ")
+	if !found {
+		return errors.New("the authorization isn't a bearer token")
+	}
+
+	if err := v.reload(); err != nil {
+		logger.UtilLog.Warnf("The JWKS [%s] can't be reloaded: %+v", v.path, err)
+	}
+
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(bearer, &claims, v.key,
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+			"ES256", "ES384", "ES512", "EdDSA"}))
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(claims.Audience, func(audience string) bool {
+		return slices.Contains(v.audiences, audience)
+	}) {
+		return fmt.Errorf("the token audience %v isn't one of %v", []string(claims.Audience), v.audiences)
+	}
+	if !slices.Contains(strings.Fields(claims.Scope), string(serviceName)) {
+		return fmt.Errorf("%w: the token scope [%s] doesn't grant %s", ErrInsufficientScope, claims.Scope, serviceName)
+	}
+	if nfTypes := v.nfTypes[serviceName]; len(nfTypes) > 0 && !slices.Contains(nfTypes, claims.NfType) {
+		return fmt.Errorf("%w: the NF type [%s] isn't allowed to %s", ErrInsufficientScope, claims.NfType, serviceName)
+	}
+	return nil
+}
+
+// jwk is a public JSON Web Key (RFC 7517) of the RSA, EC or OKP type
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJwks returns the public keys of a JSON Web Key Set by key ID, the encryption keys are ignored
+func ParseJwks(content []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("the key [%s]: %+v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("the JWKS has no signature key")
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("the curve [%s] isn't supported", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("the point isn't on the curve")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("the curve [%s] isn't supported", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("the Ed25519 key length is wrong")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("the key type [%s] isn't supported", k.Kty)
+	}
+}
@@ -0,0 +1,267 @@
+package context
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateJwk(t *testing.T, kid string) (ed25519.PrivateKey, map[string]string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	return private, map[string]string{
+		"kty": "OKP",
+		"crv": "Ed25519",
+		"kid": kid,
+		"x":   base64.RawURLEncoding.EncodeToString(public),
+	}
+}
+
+func writeJwks(t *testing.T, path string, keys ...map[string]string) {
+	content, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(path, content, 0o600))
+}
+
+func signToken(t *testing.T, private ed25519.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(private)
+	require.Nil(t, err)
+	return "Bearer " + signed
+}
+
+func TestJwksVerifier(t *testing.T) {
+	private, key := generateJwk(t, "nrf-1")
+	content, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{key}})
+	require.Nil(t, err)
+
+	verifier, err := NewJwksVerifier(&factory.OAuth2{
+		Jwks:                string(content),
+		AllowedNfTypes:      []string{"AMF"},
+		ProvisioningNfTypes: []string{"AF"},
+	}, []string{"5G_EIR"})
+	require.Nil(t, err)
+
+	claims := func(override jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"iss":    "nrf",
+			"sub":    "amf",
+			"aud":    "5G_EIR",
+			"scope":  "nnrf-disc n5g-eir-eic",
+			"nfType": "AMF",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range override {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		serviceName  models.ServiceName
+		valid        bool
+		insufficient bool
+	}{
+		{name: "Valid", token: signToken(t, private, "nrf-1", claims(nil)), valid: true},
+		{name: "AudienceList", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"aud": []string{"SMF", "5G_EIR"},
+		})), valid: true},
+		{name: "NotBearer", token: "Basic YW1mOmFtZg=="},
+		{name: "Expired", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"exp": time.Now().Add(-time.Minute).Unix(),
+		}))},
+		{name: "WithoutExpiration", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{"exp": nil}))},
+		{name: "WrongAudience", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{"aud": "UDM"}))},
+		{name: "UnknownKey", token: signToken(t, private, "nrf-2", claims(nil))},
+		{name: "WrongScope", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"scope": "nudm-sdm",
+		})), insufficient: true},
+		{name: "WrongNfType", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"nfType": "SMF",
+		})), insufficient: true},
+		{name: "ProvisioningNfType", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"scope": "n5g-eir-prov", "nfType": "AF",
+		})), serviceName: factory.EirProvServiceName, valid: true},
+		// The NF types are allowed by service, an AMF isn't one of the provisioning NF types
+		{name: "ProvisioningWrongNfType", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"scope": "n5g-eir-eic n5g-eir-prov",
+		})), serviceName: factory.EirProvServiceName, insufficient: true},
+		{name: "CheckProvisioningNfType", token: signToken(t, private, "nrf-1", claims(jwt.MapClaims{
+			"scope": "n5g-eir-eic n5g-eir-prov", "nfType": "AF",
+		})), insufficient: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceName := tt.serviceName
+			if serviceName == "" {
+				serviceName = models.ServiceName_N5G_EIR_EIC
+			}
+			err := verifier.Verify(tt.token, serviceName)
+			if tt.valid {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, tt.insufficient, errors.Is(err, ErrInsufficientScope))
+		})
+	}
+}
+
+func TestJwksVerifierProvisioningAnyNfType(t *testing.T) {
+	private, key := generateJwk(t, "nrf-1")
+	content, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{key}})
+	require.Nil(t, err)
+
+	// Without provisioning NF types, any NF type granted the provisioning scope is allowed
+	verifier, err := NewJwksVerifier(&factory.OAuth2{
+		Jwks:           string(content),
+		AllowedNfTypes: []string{"AMF"},
+	}, []string{"5G_EIR"})
+	require.Nil(t, err)
+	token := signToken(t, private, "nrf-1", jwt.MapClaims{
+		"aud":    "5G_EIR",
+		"scope":  "n5g-eir-eic n5g-eir-prov",
+		"nfType": "NEF",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	assert.Nil(t, verifier.Verify(token, factory.EirProvServiceName))
+	assert.True(t, errors.Is(verifier.Verify(token, models.ServiceName_N5G_EIR_EIC), ErrInsufficientScope))
+}
+
+func TestJwksVerifierRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	oldPrivate, oldKey := generateJwk(t, "nrf-1")
+	writeJwks(t, path, oldKey)
+
+	verifier, err := NewJwksVerifier(&factory.OAuth2{
+		JwksPath:       path,
+		AllowedNfTypes: []string{"AMF"},
+	}, []string{"5G_EIR"})
+	require.Nil(t, err)
+	claims := jwt.MapClaims{
+		"aud":    "5G_EIR",
+		"scope":  "n5g-eir-eic",
+		"nfType": "AMF",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	assert.Nil(t, verifier.Verify(signToken(t, oldPrivate, "nrf-1", claims), models.ServiceName_N5G_EIR_EIC))
+
+	newPrivate, newKey := generateJwk(t, "nrf-2")
+	writeJwks(t, path, newKey)
+	// The modification time of the file must change even on a coarse file system
+	future := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(path, future, future))
+
+	assert.Nil(t, verifier.Verify(signToken(t, newPrivate, "nrf-2", claims), models.ServiceName_N5G_EIR_EIC))
+	assert.NotNil(t, verifier.Verify(signToken(t, oldPrivate, "nrf-1", claims), models.ServiceName_N5G_EIR_EIC))
+}
+
+func TestNewJwksVerifierErrors(t *testing.T) {
+	_, err := NewJwksVerifier(&factory.OAuth2{Jwks: "{"}, []string{"5G_EIR"})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the inline JWKS is malformed")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	_, err = NewJwksVerifier(&factory.OAuth2{JwksPath: path}, []string{"5G_EIR"})
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	verifier, err := NewTokenVerifier(nil, "5c0d1a2e-7f00-4b8e-9b1c-000000000001")
+	assert.Nil(t, err)
+	assert.Nil(t, verifier)
+}
+
+func TestInitWithConfigOAuth2WithoutKeys(t *testing.T) {
+	postContent := []byte(`
+  oauth2:
+    allowedNfTypes:
+      - AMF
+  sbi:
+    scheme: http
+    registerIP: "127.0.0.13"
+    bindingIP: "127.0.0.13"
+    port: 8131`)
+
+	configFile := createConfigFile(t, postContent)
+
+	// Test the initialization with the config file
+	_, err := factory.ReadConfig(configFile.Name())
+	assert.Equal(t, err, errors.New("Config validate Error"))
+
+	// Close the config file
+	t.Cleanup(func() {
+		if err = os.RemoveAll(configFile.Name()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestInitWithConfigOAuth2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	_, key := generateJwk(t, "nrf-1")
+	writeJwks(t, path, key)
+
+	postContent := []byte(`
+  oauth2:
+    jwksPath: ` + path + `
+  sbi:
+    scheme: http
+    registerIP: "127.0.0.13"
+    bindingIP: "127.0.0.13"
+    port: 8131`)
+
+	configFile := createConfigFile(t, postContent)
+
+	// Test the initialization with the config file
+	cfg, err := factory.ReadConfig(configFile.Name())
+	require.Nil(t, err)
+	factory.EirConfig = cfg
+
+	Init()
+	t.Cleanup(func() { eirContext.SetTokenVerifier(nil) })
+
+	assert.Equal(t, []string{"AMF"}, cfg.Configuration.OAuth2.AllowedNfTypes)
+	require.NotNil(t, eirContext.GetTokenVerifier())
+	assert.NotNil(t, eirContext.AuthorizationCheck("", models.ServiceName_N5G_EIR_EIC))
+
+	// Close the config file
+	t.Cleanup(func() {
+		if err = os.RemoveAll(configFile.Name()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestTokenScope(t *testing.T) {
+	private, _ := generateJwk(t, "nrf-1")
+	token := signToken(t, private, "nrf-1", jwt.MapClaims{"scope": "n5g-eir-eic n5g-eir-prov"})
+
+	scope, err := tokenScope(token)
+	require.Nil(t, err)
+	assert.Equal(t, "n5g-eir-eic n5g-eir-prov", scope)
+
+	_, err = tokenScope(strings.TrimPrefix(token, "Bearer "))
+	assert.NotNil(t, err)
+	_, err = tokenScope("Bearer malformed")
+	assert.NotNil(t, err)
+}
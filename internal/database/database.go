@@ -1,7 +1,11 @@
 package database
 
 import (
+	"context"
+
+	"github.com/adjivas/eir/internal/database/memory"
 	"github.com/adjivas/eir/internal/database/mongodb"
+	"github.com/adjivas/eir/internal/database/sqldb"
 	"github.com/adjivas/eir/internal/logger"
 	"github.com/adjivas/eir/pkg/factory"
 	"github.com/free5gc/openapi/models"
@@ -10,17 +14,60 @@ import (
 
 const (
 	DBCONNECTOR_TYPE_MONGODB factory.DbType = "mongodb"
+	DBCONNECTOR_TYPE_MEMORY  factory.DbType = "memory"
+	DBCONNECTOR_TYPE_SQL     factory.DbType = "sql"
 )
 
 type DbConnector interface {
 	GetDataFromDB(collName string, filter bson.M) (map[string]interface{}, *models.ProblemDetails)
 	GetDataFromDBWithArg(collName string, filter bson.M, strength int) (map[string]interface{}, *models.ProblemDetails)
+	// PostDataToDB inserts the data unless the filter already matches a document, and reports whether it existed
+	PostDataToDB(collName string, filter bson.M, data map[string]interface{}) (bool, *models.ProblemDetails)
+	// PutDataToDB updates the document matched by the filter or inserts it, and reports whether it existed
+	PutDataToDB(collName string, filter bson.M, data map[string]interface{}) (bool, *models.ProblemDetails)
+	DeleteDataFromDB(collName string, filter bson.M) *models.ProblemDetails
+	// PutManyDataToDB is the bulk write of PutDataToDB, the filters and the data are paired by index
+	PutManyDataToDB(collName string, filters []bson.M, data []map[string]interface{}) *models.ProblemDetails
+	DeleteManyDataFromDB(collName string, filter bson.M) *models.ProblemDetails
+	GetManyDataFromDB(collName string, filter bson.M) ([]map[string]interface{}, *models.ProblemDetails)
+	// GetLastDataFromDB returns the document holding the greatest number in the field
+	GetLastDataFromDB(collName string, field string) (map[string]interface{}, *models.ProblemDetails)
+	// IterateDataFromDB calls the callback for each document matched by the filter until it returns an error,
+	// in the ascending order of the sort field when it isn't empty
+	IterateDataFromDB(collName string, filter bson.M, sortField string,
+		callback func(data map[string]interface{}) error) *models.ProblemDetails
+	// Ping checks that the database is reachable
+	Ping(ctx context.Context) *models.ProblemDetails
 }
 
+// NewDbConnector returns the connector of the database type, its calls are observed and traced
 func NewDbConnector(dbName factory.DbType) DbConnector {
-	if dbName == DBCONNECTOR_TYPE_MONGODB {
+	dbConnector := newDbConnector(dbName)
+	if dbConnector == nil {
+		return nil
+	}
+	return instrumentedDbConnector{DbConnector: dbConnector}
+}
+
+func newDbConnector(dbName factory.DbType) DbConnector {
+	switch dbName {
+	case DBCONNECTOR_TYPE_MONGODB:
 		return mongodb.NewMongoDbConnector(factory.EirConfig.Configuration.Mongodb)
-	} else {
+	case DBCONNECTOR_TYPE_MEMORY:
+		dbConnector, err := memory.NewMemoryDbConnector(factory.EirConfig.Configuration.Memory)
+		if err != nil {
+			logger.DbLog.Fatalf("Memory database initialization failed: %+v", err)
+			return nil
+		}
+		return dbConnector
+	case DBCONNECTOR_TYPE_SQL:
+		dbConnector, err := sqldb.NewSqlDbConnector(factory.EirConfig.Configuration.Sql)
+		if err != nil {
+			logger.DbLog.Fatalf("SQL database initialization failed: %+v", err)
+			return nil
+		}
+		return dbConnector
+	default:
 		logger.DbLog.Fatalf("Unsupported database type: %s", dbName)
 		return nil
 	}
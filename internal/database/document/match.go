@@ -0,0 +1,66 @@
+package document
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Match follows the MongoDB equality semantics: a nil value matches a missing or a null field
+func Match(data map[string]interface{}, filter bson.M) bool {
+	for key, expected := range filter {
+		value, ok := data[key]
+		if expected == nil {
+			if ok && value != nil {
+				return false
+			}
+			continue
+		}
+		if !ok || !Equal(value, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal compares two document values, the numbers are compared whatever their type
+func Equal(value interface{}, expected interface{}) bool {
+	if number, ok := ToFloat(value); ok {
+		if expectedNumber, ok := ToFloat(expected); ok {
+			return number == expectedNumber
+		}
+	}
+	return reflect.DeepEqual(value, expected)
+}
+
+// Less orders two document values: a missing value first, then the numbers whatever their type, then the strings
+func Less(value interface{}, other interface{}) bool {
+	if value == nil || other == nil {
+		return value == nil && other != nil
+	}
+	number, isNumber := ToFloat(value)
+	otherNumber, isOtherNumber := ToFloat(other)
+	if isNumber || isOtherNumber {
+		return isNumber && (!isOtherNumber || number < otherNumber)
+	}
+	text, isText := value.(string)
+	otherText, isOtherText := other.(string)
+	return isText && isOtherText && text < otherText
+}
+
+func ToFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/adjivas/eir/internal/metrics"
+	"github.com/adjivas/eir/internal/tracing"
+	"github.com/free5gc/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedDbConnector observes the duration of each call of the DbConnector and traces it as a child span of
+// its context
+type instrumentedDbConnector struct {
+	DbConnector
+
+	ctx context.Context
+}
+
+// WithContext returns the DbConnector tracing its calls as child spans of the context
+func WithContext(ctx context.Context, db DbConnector) DbConnector {
+	if instrumented, ok := db.(instrumentedDbConnector); ok {
+		instrumented.ctx = ctx
+		return instrumented
+	}
+	return db
+}
+
+// observe starts the span of the call, the returned function ends it and observes its duration
+func (db instrumentedDbConnector) observe(operation string, collName string) func() {
+	ctx := db.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	_, span := tracing.Tracer().Start(ctx, "DbConnector."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBOperation(operation), attribute.String("db.collection.name", collName)))
+	return func() {
+		span.End()
+		metrics.DatabaseDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (db instrumentedDbConnector) GetDataFromDB(collName string, filter bson.M,
+) (map[string]interface{}, *models.ProblemDetails) {
+	defer db.observe("GetDataFromDB", collName)()
+	return db.DbConnector.GetDataFromDB(collName, filter)
+}
+
+func (db instrumentedDbConnector) GetDataFromDBWithArg(collName string, filter bson.M, strength int,
+) (map[string]interface{}, *models.ProblemDetails) {
+	defer db.observe("GetDataFromDBWithArg", collName)()
+	return db.DbConnector.GetDataFromDBWithArg(collName, filter, strength)
+}
+
+func (db instrumentedDbConnector) PostDataToDB(collName string, filter bson.M, data map[string]interface{},
+) (bool, *models.ProblemDetails) {
+	defer db.observe("PostDataToDB", collName)()
+	return db.DbConnector.PostDataToDB(collName, filter, data)
+}
+
+func (db instrumentedDbConnector) PutDataToDB(collName string, filter bson.M, data map[string]interface{},
+) (bool, *models.ProblemDetails) {
+	defer db.observe("PutDataToDB", collName)()
+	return db.DbConnector.PutDataToDB(collName, filter, data)
+}
+
+func (db instrumentedDbConnector) DeleteDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	defer db.observe("DeleteDataFromDB", collName)()
+	return db.DbConnector.DeleteDataFromDB(collName, filter)
+}
+
+func (db instrumentedDbConnector) PutManyDataToDB(collName string, filters []bson.M, data []map[string]interface{},
+) *models.ProblemDetails {
+	defer db.observe("PutManyDataToDB", collName)()
+	return db.DbConnector.PutManyDataToDB(collName, filters, data)
+}
+
+func (db instrumentedDbConnector) DeleteManyDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	defer db.observe("DeleteManyDataFromDB", collName)()
+	return db.DbConnector.DeleteManyDataFromDB(collName, filter)
+}
+
+func (db instrumentedDbConnector) GetManyDataFromDB(collName string, filter bson.M,
+) ([]map[string]interface{}, *models.ProblemDetails) {
+	defer db.observe("GetManyDataFromDB", collName)()
+	return db.DbConnector.GetManyDataFromDB(collName, filter)
+}
+
+func (db instrumentedDbConnector) GetLastDataFromDB(collName string, field string,
+) (map[string]interface{}, *models.ProblemDetails) {
+	defer db.observe("GetLastDataFromDB", collName)()
+	return db.DbConnector.GetLastDataFromDB(collName, field)
+}
+
+func (db instrumentedDbConnector) IterateDataFromDB(collName string, filter bson.M, sortField string,
+	callback func(data map[string]interface{}) error,
+) *models.ProblemDetails {
+	defer db.observe("IterateDataFromDB", collName)()
+	return db.DbConnector.IterateDataFromDB(collName, filter, sortField, callback)
+}
+
+func (db instrumentedDbConnector) Ping(ctx context.Context) *models.ProblemDetails {
+	defer db.observe("Ping", "")()
+	return db.DbConnector.Ping(ctx)
+}
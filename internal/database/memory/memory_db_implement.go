@@ -0,0 +1,289 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adjivas/eir/internal/database/document"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"go.mongodb.org/mongo-driver/bson"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	EQUIPMENT_UNKNOWN       = "Data not found"
+	EQUIPMENT_UNKNOWN_CAUSE = "DATA_NOT_FOUND"
+)
+
+// MemoryDbConnector keeps every collection in memory, it's meant for labs and CI without MongoDB
+type MemoryDbConnector struct {
+	*factory.Memory
+
+	mu          sync.RWMutex
+	collections map[string][]map[string]interface{}
+}
+
+func NewMemoryDbConnector(memory *factory.Memory) (*MemoryDbConnector, error) {
+	m := &MemoryDbConnector{
+		Memory:      memory,
+		collections: make(map[string][]map[string]interface{}),
+	}
+	if memory != nil && memory.Seed != "" {
+		if err := m.seed(memory.Seed); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// seed loads a YAML or JSON file which maps each collection name to its documents
+func (m *MemoryDbConnector) seed(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("memory seed: %+v", err)
+	}
+
+	var collections map[string][]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, &collections)
+	default:
+		err = yaml.Unmarshal(content, &collections)
+	}
+	if err != nil {
+		return fmt.Errorf("memory seed [%s]: %+v", path, err)
+	}
+
+	count := 0
+	for collName, documents := range collections {
+		for _, document := range documents {
+			data, ok := normalize(document).(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("memory seed [%s]: a document of %s isn't a mapping", path, collName)
+			}
+			m.collections[collName] = append(m.collections[collName], data)
+			count++
+		}
+	}
+	logger.DbLog.Infof("Seeded %d documents from [%s]", count, path)
+	return nil
+}
+
+// normalize converts the YAML mappings into JSON-like mappings
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		data := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			data[fmt.Sprint(key)] = normalize(field)
+		}
+		return data
+	case map[string]interface{}:
+		data := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			data[key] = normalize(field)
+		}
+		return data
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, field := range v {
+			array[i] = normalize(field)
+		}
+		return array
+	default:
+		return v
+	}
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		copied[key] = value
+	}
+	return copied
+}
+
+// find returns the index of the first document matched by the filter, the lock must be held
+func (m *MemoryDbConnector) find(collName string, filter bson.M) int {
+	for i, data := range m.collections[collName] {
+		if document.Match(data, filter) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryDbConnector) GetDataFromDB(collName string, filter bson.M) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if i := m.find(collName, filter); i >= 0 {
+		return copyData(m.collections[collName][i]), nil
+	}
+	return nil, &models.ProblemDetails{
+		Title:  EQUIPMENT_UNKNOWN,
+		Status: http.StatusNotFound,
+		Cause:  EQUIPMENT_UNKNOWN_CAUSE,
+	}
+}
+
+// GetDataFromDBWithArg ignores the collation strength, the memory comparisons are always exact
+func (m *MemoryDbConnector) GetDataFromDBWithArg(collName string, filter bson.M, strength int) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	return m.GetDataFromDB(collName, filter)
+}
+
+func (m *MemoryDbConnector) PostDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.find(collName, filter) >= 0 {
+		return true, nil
+	}
+	m.collections[collName] = append(m.collections[collName], copyData(data))
+	return false, nil
+}
+
+func (m *MemoryDbConnector) PutDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.put(collName, filter, data), nil
+}
+
+// put merges the data like a MongoDB $set, the lock must be held
+func (m *MemoryDbConnector) put(collName string, filter bson.M, data map[string]interface{}) bool {
+	if i := m.find(collName, filter); i >= 0 {
+		document := m.collections[collName][i]
+		for key, value := range data {
+			document[key] = value
+		}
+		return true
+	}
+	m.collections[collName] = append(m.collections[collName], copyData(data))
+	return false
+}
+
+func (m *MemoryDbConnector) DeleteDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := m.find(collName, filter); i >= 0 {
+		documents := m.collections[collName]
+		m.collections[collName] = append(documents[:i], documents[i+1:]...)
+	}
+	return nil
+}
+
+func (m *MemoryDbConnector) PutManyDataToDB(collName string, filters []bson.M, data []map[string]interface{}) (
+	problemDetails *models.ProblemDetails,
+) {
+	if len(filters) != len(data) {
+		return openapi.ProblemDetailsSystemFailure(
+			fmt.Sprintf("PutManyDataToDB has %d filters for %d documents", len(filters), len(data)))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, filter := range filters {
+		m.put(collName, filter, data[i])
+	}
+	return nil
+}
+
+func (m *MemoryDbConnector) DeleteManyDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var kept []map[string]interface{}
+	for _, data := range m.collections[collName] {
+		if !document.Match(data, filter) {
+			kept = append(kept, data)
+		}
+	}
+	m.collections[collName] = kept
+	return nil
+}
+
+func (m *MemoryDbConnector) GetManyDataFromDB(collName string, filter bson.M) (
+	[]map[string]interface{}, *models.ProblemDetails,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []map[string]interface{}
+	for _, data := range m.collections[collName] {
+		if document.Match(data, filter) {
+			matched = append(matched, copyData(data))
+		}
+	}
+	return matched, nil
+}
+
+func (m *MemoryDbConnector) GetLastDataFromDB(collName string, field string) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last map[string]interface{}
+	var lastNumber float64
+	for _, data := range m.collections[collName] {
+		number, ok := document.ToFloat(data[field])
+		if ok && (last == nil || number > lastNumber) {
+			last, lastNumber = data, number
+		}
+	}
+	if last == nil {
+		return nil, &models.ProblemDetails{
+			Title:  EQUIPMENT_UNKNOWN,
+			Status: http.StatusNotFound,
+			Cause:  EQUIPMENT_UNKNOWN_CAUSE,
+		}
+	}
+	return copyData(last), nil
+}
+
+func (m *MemoryDbConnector) IterateDataFromDB(collName string, filter bson.M, sortField string,
+	callback func(data map[string]interface{}) error,
+) *models.ProblemDetails {
+	// The callback may write into the connector, so it's called on a snapshot without the lock
+	matched, problemDetails := m.GetManyDataFromDB(collName, filter)
+	if problemDetails != nil {
+		return problemDetails
+	}
+	if sortField != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return document.Less(matched[i][sortField], matched[j][sortField])
+		})
+	}
+	for _, data := range matched {
+		if err := callback(data); err != nil {
+			return openapi.ProblemDetailsSystemFailure(err.Error())
+		}
+	}
+	return nil
+}
+
+// Ping always succeeds, the memory database is in the process
+func (m *MemoryDbConnector) Ping(ctx context.Context) *models.ProblemDetails {
+	return nil
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const collName = "policyData.ues.eirData"
+
+func createSeedFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("can't write the seed file: %+v", err)
+	}
+	return path
+}
+
+func TestNewMemoryDbConnectorWithYAMLSeed(t *testing.T) {
+	seed := createSeedFile(t, "eirdata.yaml", `policyData.ues.eirData:
+  - pei: imei-012345678901234
+    equipment_status: WHITELISTED
+  - pei: imei-012345678901234
+    supi: imsi-208930000000001
+    equipment_status: BLACKLISTED
+  - pei: imei-43
+    gpsi: msisdn-00043
+    equipment_status: BLACKLISTED
+`)
+
+	m, err := NewMemoryDbConnector(&factory.Memory{Seed: seed})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		status string
+	}{
+		{
+			name:   "PEI",
+			filter: bson.M{"pei": "imei-012345678901234"},
+			status: "WHITELISTED",
+		},
+		{
+			name:   "PEI and SUPI",
+			filter: bson.M{"pei": "imei-012345678901234", "supi": "imsi-208930000000001"},
+			status: "BLACKLISTED",
+		},
+		{
+			name:   "PEI and GPSI",
+			filter: bson.M{"pei": "imei-43", "gpsi": "msisdn-00043"},
+			status: "BLACKLISTED",
+		},
+		{
+			name:   "PEI and missing SUPI",
+			filter: bson.M{"pei": "imei-43", "supi": nil},
+			status: "BLACKLISTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, problemDetails := m.GetDataFromDB(collName, tt.filter)
+			require.Nil(t, problemDetails)
+			assert.Equal(t, tt.status, data["equipment_status"])
+		})
+	}
+
+	t.Run("Not found", func(t *testing.T) {
+		_, problemDetails := m.GetDataFromDB(collName, bson.M{"pei": "imei-43", "gpsi": "msisdn-00042"})
+		require.NotNil(t, problemDetails)
+		assert.Equal(t, EQUIPMENT_UNKNOWN_CAUSE, problemDetails.Cause)
+	})
+}
+
+func TestNewMemoryDbConnectorWithJSONSeed(t *testing.T) {
+	seed := createSeedFile(t, "eirdata.json",
+		`{"policyData.ues.eirData": [{"pei": "imei-42", "equipment_status": "BLACKLISTED"}]}`)
+
+	m, err := NewMemoryDbConnector(&factory.Memory{Seed: seed})
+	require.Nil(t, err)
+
+	data, problemDetails := m.GetDataFromDB(collName, bson.M{"pei": "imei-42"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, "BLACKLISTED", data["equipment_status"])
+}
+
+func TestNewMemoryDbConnectorWithMissingSeed(t *testing.T) {
+	_, err := NewMemoryDbConnector(&factory.Memory{Seed: filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.NotNil(t, err)
+}
+
+func TestMemoryDbConnectorWrites(t *testing.T) {
+	m, err := NewMemoryDbConnector(nil)
+	require.Nil(t, err)
+
+	existed, problemDetails := m.PostDataToDB(collName, bson.M{"pei": "imei-42", "supi": nil},
+		map[string]interface{}{"pei": "imei-42", "equipment_status": "WHITELISTED"})
+	require.Nil(t, problemDetails)
+	assert.False(t, existed)
+
+	existed, problemDetails = m.PostDataToDB(collName, bson.M{"pei": "imei-42", "supi": nil},
+		map[string]interface{}{"pei": "imei-42", "equipment_status": "BLACKLISTED"})
+	require.Nil(t, problemDetails)
+	assert.True(t, existed)
+
+	existed, problemDetails = m.PutDataToDB(collName, bson.M{"pei": "imei-42", "supi": nil},
+		map[string]interface{}{"equipment_status": "BLACKLISTED"})
+	require.Nil(t, problemDetails)
+	assert.True(t, existed)
+
+	problemDetails = m.PutManyDataToDB(collName,
+		[]bson.M{{"pei": "imei-43"}, {"pei": "imei-44"}},
+		[]map[string]interface{}{
+			{"pei": "imei-43", "equipment_status": "WHITELISTED"},
+			{"pei": "imei-44", "equipment_status": "WHITELISTED"},
+		})
+	require.Nil(t, problemDetails)
+
+	all, problemDetails := m.GetManyDataFromDB(collName, bson.M{})
+	require.Nil(t, problemDetails)
+	assert.ElementsMatch(t, []map[string]interface{}{
+		{"pei": "imei-42", "equipment_status": "BLACKLISTED"},
+		{"pei": "imei-43", "equipment_status": "WHITELISTED"},
+		{"pei": "imei-44", "equipment_status": "WHITELISTED"},
+	}, all)
+
+	whitelisted := 0
+	problemDetails = m.IterateDataFromDB(collName, bson.M{"equipment_status": "WHITELISTED"}, "",
+		func(data map[string]interface{}) error {
+			whitelisted++
+			return nil
+		})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, 2, whitelisted)
+
+	require.Nil(t, m.DeleteDataFromDB(collName, bson.M{"pei": "imei-42"}))
+	require.Nil(t, m.DeleteManyDataFromDB(collName, bson.M{"equipment_status": "WHITELISTED"}))
+
+	all, problemDetails = m.GetManyDataFromDB(collName, bson.M{})
+	require.Nil(t, problemDetails)
+	assert.Empty(t, all)
+}
+
+func TestMemoryDbConnectorIterateSorted(t *testing.T) {
+	m, err := NewMemoryDbConnector(&factory.Memory{})
+	require.Nil(t, err)
+
+	// A JSON document holds its sequence as float64
+	for _, sequence := range []interface{}{int64(3), int64(1), float64(2)} {
+		_, problemDetails := m.PostDataToDB("policyData.ues.eirAuditRecords", bson.M{"sequence": sequence},
+			map[string]interface{}{"sequence": sequence})
+		require.Nil(t, problemDetails)
+	}
+
+	var sequences []interface{}
+	problemDetails := m.IterateDataFromDB("policyData.ues.eirAuditRecords", bson.M{}, "sequence",
+		func(data map[string]interface{}) error {
+			sequences = append(sequences, data["sequence"])
+			return nil
+		})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, []interface{}{int64(1), float64(2), int64(3)}, sequences)
+}
@@ -1,6 +1,9 @@
 package mongodb
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/adjivas/eir/internal/logger"
@@ -9,6 +12,8 @@ import (
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/util/mongoapi"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -16,6 +21,40 @@ const (
 	EQUIPMENT_UNKNOWN_CAUSE = "DATA_NOT_FOUND"
 )
 
+// index is an index of a collection looked up by a field
+type index struct {
+	collName string
+	field    string
+	unique   bool
+}
+
+// indexes are created by CreateIndexes, the TAC models are imported and looked up by TAC
+var indexes = []index{
+	{collName: "policyData.ues.eirTacModels", field: "tac", unique: true},
+	{collName: "policyData.ues.eirTacRules", field: "tac"},
+	// The replicas can't write two audit records of the same sequence, so the chain can't fork
+	{collName: "policyData.ues.eirAuditRecords", field: "sequence", unique: true},
+	{collName: "policyData.ues.eirAuditRecords", field: "hash", unique: true},
+}
+
+// CreateIndexes creates the missing indexes of the database, the existing ones are kept
+func CreateIndexes(ctx context.Context, name string) error {
+	if mongoapi.Client == nil {
+		return fmt.Errorf("MongoDB isn't connected")
+	}
+	for _, index := range indexes {
+		model := mongo.IndexModel{
+			Keys:    bson.D{{Key: index.field, Value: 1}},
+			Options: options.Index().SetUnique(index.unique),
+		}
+		collection := mongoapi.Client.Database(name).Collection(index.collName)
+		if _, err := collection.Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("the %s index of %s can't be created: %+v", index.field, index.collName, err)
+		}
+	}
+	return nil
+}
+
 type MongoDbConnector struct {
 	*factory.Mongodb
 }
@@ -62,3 +101,158 @@ func (m MongoDbConnector) GetDataFromDBWithArg(collName string, filter bson.M, s
 
 	return data, nil
 }
+
+func (m MongoDbConnector) PostDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	existed, err := mongoapi.RestfulAPIPutOneNotUpdate(collName, filter, data)
+	if err != nil {
+		return false, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return existed, nil
+}
+
+func (m MongoDbConnector) PutDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	existed, err := mongoapi.RestfulAPIPutOne(collName, filter, data)
+	if err != nil {
+		return false, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return existed, nil
+}
+
+func (m MongoDbConnector) DeleteDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	if err := mongoapi.RestfulAPIDeleteOne(collName, filter); err != nil {
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return nil
+}
+
+func (m MongoDbConnector) PutManyDataToDB(collName string, filters []bson.M, data []map[string]interface{}) (
+	problemDetails *models.ProblemDetails,
+) {
+	if len(filters) != len(data) {
+		return openapi.ProblemDetailsSystemFailure(
+			fmt.Sprintf("PutManyDataToDB has %d filters for %d documents", len(filters), len(data)))
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	if mongoapi.Client == nil {
+		return openapi.ProblemDetailsSystemFailure("PutManyDataToDB err: client isn't connected")
+	}
+
+	// The upserts are written in one ordered bulk, so a filter repeated in the batch keeps its last data
+	writes := make([]mongo.WriteModel, 0, len(filters))
+	for i, filter := range filters {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": data[i]}).
+			SetUpsert(true))
+	}
+	collection := mongoapi.Client.Database(m.Name).Collection(collName)
+	if _, err := collection.BulkWrite(context.TODO(), writes, options.BulkWrite().SetOrdered(true)); err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("PutManyDataToDB BulkWrite err: %+v", err))
+	}
+	return nil
+}
+
+func (m MongoDbConnector) DeleteManyDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	if mongoapi.Client == nil {
+		return openapi.ProblemDetailsSystemFailure("DeleteManyDataFromDB err: client isn't connected")
+	}
+
+	collection := mongoapi.Client.Database(m.Name).Collection(collName)
+	if _, err := collection.DeleteMany(context.TODO(), filter); err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("DeleteManyDataFromDB err: %+v", err))
+	}
+	return nil
+}
+
+func (m MongoDbConnector) GetManyDataFromDB(collName string, filter bson.M) (
+	[]map[string]interface{}, *models.ProblemDetails,
+) {
+	data, err := mongoapi.RestfulAPIGetMany(collName, filter)
+	if err != nil {
+		return nil, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return data, nil
+}
+
+func (m MongoDbConnector) GetLastDataFromDB(collName string, field string) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	if mongoapi.Client == nil {
+		return nil, openapi.ProblemDetailsSystemFailure("GetLastDataFromDB err: client isn't connected")
+	}
+
+	collection := mongoapi.Client.Database(m.Name).Collection(collName)
+	result := collection.FindOne(context.TODO(), bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: field, Value: -1}}))
+	var data map[string]interface{}
+	if err := result.Decode(&data); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, &models.ProblemDetails{
+				Title:  EQUIPMENT_UNKNOWN,
+				Status: http.StatusNotFound,
+				Cause:  EQUIPMENT_UNKNOWN_CAUSE,
+			}
+		}
+		return nil, openapi.ProblemDetailsSystemFailure(fmt.Sprintf("GetLastDataFromDB FindOne err: %+v", err))
+	}
+	// Delete "_id" entry which is auto-inserted by MongoDB
+	delete(data, "_id")
+	return data, nil
+}
+
+// IterateDataFromDB streams the documents of the cursor, they aren't loaded at once
+func (m MongoDbConnector) IterateDataFromDB(collName string, filter bson.M, sortField string,
+	callback func(data map[string]interface{}) error,
+) *models.ProblemDetails {
+	if mongoapi.Client == nil {
+		return openapi.ProblemDetailsSystemFailure("IterateDataFromDB err: client isn't connected")
+	}
+
+	ctx := context.TODO()
+	collection := mongoapi.Client.Database(m.Name).Collection(collName)
+	findOptions := options.Find()
+	if sortField != "" {
+		findOptions.SetSort(bson.D{{Key: sortField, Value: 1}})
+	}
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("IterateDataFromDB Find err: %+v", err))
+	}
+	defer func() {
+		if err := cursor.Close(ctx); err != nil {
+			logger.DbLog.Warnf("IterateDataFromDB Close err: %+v", err)
+		}
+	}()
+
+	for cursor.Next(ctx) {
+		var data map[string]interface{}
+		if err := cursor.Decode(&data); err != nil {
+			return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("IterateDataFromDB Decode err: %+v", err))
+		}
+		// Delete "_id" entry which is auto-inserted by MongoDB
+		delete(data, "_id")
+		if err := callback(data); err != nil {
+			return openapi.ProblemDetailsSystemFailure(err.Error())
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("IterateDataFromDB err: %+v", err))
+	}
+	return nil
+}
+
+func (m MongoDbConnector) Ping(ctx context.Context) *models.ProblemDetails {
+	if mongoapi.Client == nil {
+		return openapi.ProblemDetailsSystemFailure("MongoDB isn't connected")
+	}
+	if err := mongoapi.Client.Ping(ctx, nil); err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("Ping err: %+v", err))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package mongodb
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/util/mongoapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+const collName = "policyData.ues.eirData"
+
+// newMockDbConnector connects the connector to the mocked deployment of the test, the commands sent are recorded
+func newMockDbConnector(mt *mtest.T) MongoDbConnector {
+	client := mongoapi.Client
+	mongoapi.Client = mt.Client
+	mt.Cleanup(func() {
+		mongoapi.Client = client
+	})
+	return NewMongoDbConnector(&factory.Mongodb{Name: mt.DB.Name()})
+}
+
+// command returns the field of the next command sent to the mocked deployment
+func command(mt *mtest.T, name string, field string) bson.RawValue {
+	event := mt.GetStartedEvent()
+	require.NotNil(mt, event)
+	require.Equal(mt, name, event.CommandName)
+	return event.Command.Lookup(field)
+}
+
+func TestMongoDbConnectorPutManyDataToDB(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("Upserts", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		problemDetails := m.PutManyDataToDB(collName,
+			[]bson.M{{"pei": "imei-43", "supi": nil}, {"pei": "imei-44", "supi": "imsi-208930000000044"}},
+			[]map[string]interface{}{
+				{"pei": "imei-43", "equipment_status": "WHITELISTED"},
+				{"pei": "imei-44", "supi": "imsi-208930000000044", "equipment_status": "BLACKLISTED"},
+			})
+		require.Nil(mt, problemDetails)
+
+		event := mt.GetStartedEvent()
+		require.NotNil(mt, event)
+		require.Equal(mt, "update", event.CommandName)
+		assert.True(mt, event.Command.Lookup("ordered").Boolean())
+
+		var updates []bson.M
+		require.Nil(mt, event.Command.Lookup("updates").Unmarshal(&updates))
+		require.Len(mt, updates, 2)
+		assert.Equal(mt, bson.M{"pei": "imei-43", "supi": nil}, updates[0]["q"])
+		assert.Equal(mt, bson.M{"$set": bson.M{"pei": "imei-43", "equipment_status": "WHITELISTED"}}, updates[0]["u"])
+		assert.Equal(mt, true, updates[0]["upsert"])
+		assert.Equal(mt, bson.M{"pei": "imei-44", "supi": "imsi-208930000000044"}, updates[1]["q"])
+		assert.Equal(mt, bson.M{"$set": bson.M{
+			"pei": "imei-44", "supi": "imsi-208930000000044", "equipment_status": "BLACKLISTED",
+		}}, updates[1]["u"])
+		assert.Equal(mt, true, updates[1]["upsert"])
+	})
+
+	mt.Run("Unpaired", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+
+		problemDetails := m.PutManyDataToDB(collName, []bson.M{{"pei": "imei-43"}}, nil)
+		require.NotNil(mt, problemDetails)
+		assert.Equal(mt, "PutManyDataToDB has 1 filters for 0 documents", problemDetails.Detail)
+		assert.Nil(mt, mt.GetStartedEvent())
+	})
+
+	mt.Run("Empty", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+
+		require.Nil(mt, m.PutManyDataToDB(collName, nil, nil))
+		assert.Nil(mt, mt.GetStartedEvent())
+	})
+}
+
+func TestMongoDbConnectorDeleteManyDataFromDB(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("Filter", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		require.Nil(mt, m.DeleteManyDataFromDB(collName, bson.M{"pei": "imei-42"}))
+
+		var deletes []bson.M
+		require.Nil(mt, command(mt, "delete", "deletes").Unmarshal(&deletes))
+		require.Len(mt, deletes, 1)
+		assert.Equal(mt, bson.M{"pei": "imei-42"}, deletes[0]["q"])
+		// A limit of 0 deletes every document matched
+		assert.EqualValues(mt, 0, deletes[0]["limit"])
+	})
+}
+
+func TestMongoDbConnectorGetLastDataFromDB(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("Last", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		namespace := fmt.Sprintf("%s.%s", mt.DB.Name(), collName)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "62b1f1c0a1b2c3d4e5f60718"},
+			{Key: "sequence", Value: int64(3)},
+			{Key: "hash", Value: "c3"},
+		}))
+
+		data, problemDetails := m.GetLastDataFromDB(collName, "sequence")
+		require.Nil(mt, problemDetails)
+		assert.Equal(mt, map[string]interface{}{"sequence": int64(3), "hash": "c3"}, data)
+
+		var sort bson.D
+		require.Nil(mt, command(mt, "find", "sort").Unmarshal(&sort))
+		assert.Equal(mt, bson.D{{Key: "sequence", Value: int32(-1)}}, sort)
+	})
+
+	mt.Run("Empty", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		namespace := fmt.Sprintf("%s.%s", mt.DB.Name(), collName)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch))
+
+		_, problemDetails := m.GetLastDataFromDB(collName, "sequence")
+		require.NotNil(mt, problemDetails)
+		assert.Equal(mt, int32(http.StatusNotFound), problemDetails.Status)
+		assert.Equal(mt, EQUIPMENT_UNKNOWN_CAUSE, problemDetails.Cause)
+	})
+}
+
+func TestMongoDbConnectorIterateDataFromDB(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	documents := []bson.D{
+		{{Key: "_id", Value: "62b1f1c0a1b2c3d4e5f60718"}, {Key: "pei", Value: "imei-42"}},
+		{{Key: "_id", Value: "62b1f1c0a1b2c3d4e5f60719"}, {Key: "pei", Value: "imei-43"}},
+	}
+
+	mt.Run("Documents", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		namespace := fmt.Sprintf("%s.%s", mt.DB.Name(), collName)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, documents...))
+
+		var iterated []map[string]interface{}
+		problemDetails := m.IterateDataFromDB(collName, bson.M{"equipment_status": "WHITELISTED"}, "",
+			func(data map[string]interface{}) error {
+				iterated = append(iterated, data)
+				return nil
+			})
+		require.Nil(mt, problemDetails)
+		assert.Equal(mt, []map[string]interface{}{{"pei": "imei-42"}, {"pei": "imei-43"}}, iterated)
+
+		var filter bson.M
+		require.Nil(mt, command(mt, "find", "filter").Unmarshal(&filter))
+		assert.Equal(mt, bson.M{"equipment_status": "WHITELISTED"}, filter)
+	})
+
+	mt.Run("Sorted", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		namespace := fmt.Sprintf("%s.%s", mt.DB.Name(), collName)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, documents...))
+
+		require.Nil(mt, m.IterateDataFromDB(collName, bson.M{}, "sequence", func(data map[string]interface{}) error {
+			return nil
+		}))
+
+		// The documents are sorted by the deployment
+		var sort bson.D
+		require.Nil(mt, command(mt, "find", "sort").Unmarshal(&sort))
+		assert.Equal(mt, bson.D{{Key: "sequence", Value: int32(1)}}, sort)
+	})
+
+	mt.Run("Interrupted", func(mt *mtest.T) {
+		m := newMockDbConnector(mt)
+		namespace := fmt.Sprintf("%s.%s", mt.DB.Name(), collName)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, documents...))
+
+		iterated := 0
+		problemDetails := m.IterateDataFromDB(collName, bson.M{}, "", func(data map[string]interface{}) error {
+			iterated++
+			return fmt.Errorf("the callback has failed")
+		})
+		require.NotNil(mt, problemDetails)
+		assert.Equal(mt, "the callback has failed", problemDetails.Detail)
+		assert.Equal(mt, 1, iterated)
+	})
+}
+
+func TestCreateIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+
+	mt.Run("Indexes", func(mt *mtest.T) {
+		newMockDbConnector(mt)
+		for range indexes {
+			mt.AddMockResponses(mtest.CreateSuccessResponse())
+		}
+
+		require.Nil(mt, CreateIndexes(mtest.Background, mt.DB.Name()))
+
+		for _, index := range indexes {
+			event := mt.GetStartedEvent()
+			require.NotNil(mt, event)
+			require.Equal(mt, "createIndexes", event.CommandName)
+			assert.Equal(mt, index.collName, event.Command.Lookup("createIndexes").StringValue())
+
+			var models []bson.M
+			require.Nil(mt, event.Command.Lookup("indexes").Unmarshal(&models))
+			require.Len(mt, models, 1)
+			assert.Equal(mt, bson.M{index.field: int32(1)}, models[0]["key"])
+			assert.Equal(mt, index.unique, models[0]["unique"])
+		}
+	})
+
+	mt.Run("Failure", func(mt *mtest.T) {
+		newMockDbConnector(mt)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    11000,
+			Message: "E11000 duplicate key error",
+			Name:    "DuplicateKey",
+		}))
+
+		err := CreateIndexes(mtest.Background, mt.DB.Name())
+		require.NotNil(mt, err)
+		assert.Contains(mt, err.Error(), "the tac index of policyData.ues.eirTacModels can't be created")
+	})
+}
+
+func TestMongoDbConnectorDisconnected(t *testing.T) {
+	client := mongoapi.Client
+	mongoapi.Client = nil
+	defer func() {
+		mongoapi.Client = client
+	}()
+	m := NewMongoDbConnector(&factory.Mongodb{Name: "free5gc"})
+
+	problemDetails := m.PutManyDataToDB(collName, []bson.M{{"pei": "imei-42"}},
+		[]map[string]interface{}{{"pei": "imei-42"}})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "PutManyDataToDB err: client isn't connected", problemDetails.Detail)
+
+	problemDetails = m.DeleteManyDataFromDB(collName, bson.M{"pei": "imei-42"})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "DeleteManyDataFromDB err: client isn't connected", problemDetails.Detail)
+
+	_, problemDetails = m.GetLastDataFromDB(collName, "sequence")
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "GetLastDataFromDB err: client isn't connected", problemDetails.Detail)
+
+	problemDetails = m.IterateDataFromDB(collName, bson.M{}, "", func(data map[string]interface{}) error {
+		return nil
+	})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "IterateDataFromDB err: client isn't connected", problemDetails.Detail)
+
+	assert.NotNil(t, CreateIndexes(mtest.Background, "free5gc"))
+}
@@ -0,0 +1,213 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type dialect struct {
+	driverName string
+	// autoIncrement is the type of an auto-incremented primary key
+	autoIncrement string
+}
+
+var dialects = map[string]dialect{
+	factory.SqlDriverSqlite: {
+		driverName:    "sqlite",
+		autoIncrement: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	},
+	factory.SqlDriverPostgres: {
+		driverName:    "postgres",
+		autoIncrement: "BIGSERIAL PRIMARY KEY",
+	},
+}
+
+// migration creates the schema of a version, the collections are then moved from the documents table to their
+// new tables
+type migration struct {
+	statements  func(d dialect) []string
+	collections []string
+}
+
+// migrations are applied in order, each one is applied once and recorded in schema_migrations
+var migrations = []migration{
+	{statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE equipment_status (
+				id ` + d.autoIncrement + `,
+				pei VARCHAR(64) NOT NULL,
+				supi VARCHAR(64),
+				gpsi VARCHAR(64),
+				equipment_status VARCHAR(32) NOT NULL,
+				reason TEXT,
+				valid_from TIMESTAMP,
+				valid_until TIMESTAMP
+			)`,
+			`CREATE INDEX equipment_status_pei ON equipment_status (pei)`,
+			`CREATE TABLE documents (
+				id ` + d.autoIncrement + `,
+				collection VARCHAR(255) NOT NULL,
+				data TEXT NOT NULL
+			)`,
+			`CREATE INDEX documents_collection ON documents (collection)`,
+		}
+	}},
+	{statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE tac_models (
+				id ` + d.autoIncrement + `,
+				tac VARCHAR(8) NOT NULL,
+				brand TEXT,
+				model TEXT,
+				device_type TEXT
+			)`,
+			`CREATE UNIQUE INDEX tac_models_tac ON tac_models (tac)`,
+		}
+	}},
+	{statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE pei_observations (
+				id ` + d.autoIncrement + `,
+				pei VARCHAR(64) NOT NULL,
+				supi VARCHAR(64) NOT NULL,
+				observed_at TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX pei_observations_pei ON pei_observations (pei)`,
+		}
+	}},
+	{statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE tac_rules (
+				id ` + d.autoIncrement + `,
+				tac VARCHAR(8) NOT NULL,
+				snr_start VARCHAR(6),
+				snr_end VARCHAR(6),
+				equipment_status VARCHAR(32) NOT NULL,
+				reason TEXT
+			)`,
+			`CREATE INDEX tac_rules_tac ON tac_rules (tac)`,
+			`CREATE TABLE cloned_peis (
+				id ` + d.autoIncrement + `,
+				pei VARCHAR(64) NOT NULL,
+				supis TEXT,
+				detected_at TIMESTAMP
+			)`,
+			`CREATE UNIQUE INDEX cloned_peis_pei ON cloned_peis (pei)`,
+		}
+	}, collections: []string{TacRuleCollName, ClonedPeiCollName}},
+	{statements: func(d dialect) []string {
+		return []string{
+			`CREATE TABLE audit_records (
+				id ` + d.autoIncrement + `,
+				sequence BIGINT NOT NULL,
+				time TEXT NOT NULL,
+				event VARCHAR(64) NOT NULL,
+				consumer_nf_id VARCHAR(64),
+				pei VARCHAR(64),
+				supi VARCHAR(64),
+				gpsi VARCHAR(64),
+				result VARCHAR(32),
+				matched_rule TEXT,
+				model TEXT,
+				previous_hash VARCHAR(64),
+				hash VARCHAR(64) NOT NULL
+			)`,
+			// The replicas can't write two records of the same sequence, so the chain can't fork
+			`CREATE UNIQUE INDEX audit_records_sequence ON audit_records (sequence)`,
+			`CREATE UNIQUE INDEX audit_records_hash ON audit_records (hash)`,
+		}
+	}, collections: []string{AuditRecordCollName}},
+}
+
+// migrate brings the schema up to the last migration
+func migrate(db *sql.DB, d dialect) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("sql schema_migrations: %+v", err)
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return fmt.Errorf("sql schema version: %+v", err)
+	}
+
+	for i := int(version.Int64); i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("sql begin: %+v", err)
+		}
+		if err = applyMigration(tx, d, i); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logger.DbLog.Warnf("sql rollback: %+v", rollbackErr)
+			}
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("sql commit: %+v", err)
+		}
+		logger.DbLog.Infof("The SQL schema is migrated to the version %d", i+1)
+	}
+	return nil
+}
+
+func applyMigration(tx *sql.Tx, d dialect, index int) error {
+	for _, statement := range migrations[index].statements(d) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("sql migration %d: %+v", index+1, err)
+		}
+	}
+	for _, collName := range migrations[index].collections {
+		if err := moveDocuments(tx, collName); err != nil {
+			return fmt.Errorf("sql migration %d: %+v", index+1, err)
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES ($1, CURRENT_TIMESTAMP)",
+		index+1); err != nil {
+		return fmt.Errorf("sql migration %d: %+v", index+1, err)
+	}
+	return nil
+}
+
+// moveDocuments moves the documents of the collection into its table, the fields without column are dropped
+func moveDocuments(tx *sql.Tx, collName string) error {
+	t := tables[collName]
+	var documents []map[string]interface{}
+	err := queryDocuments(tx, collName, nil, func(id int64, data map[string]interface{}) error {
+		documents = append(documents, data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, data := range documents {
+		for field := range data {
+			if !t.isColumn(field) {
+				logger.DbLog.Warnf("The %s field of a %s document has no column, it's dropped", field, collName)
+				delete(data, field)
+			}
+		}
+		if err = insert(tx, collName, data); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec("DELETE FROM documents WHERE collection = $1", collName); err != nil {
+		return fmt.Errorf("sql delete: %+v", err)
+	}
+	if len(documents) > 0 {
+		logger.DbLog.Infof("%d %s documents are moved to the %s table", len(documents), collName, t.name)
+	}
+	return nil
+}
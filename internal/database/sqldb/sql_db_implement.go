@@ -0,0 +1,762 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adjivas/eir/internal/database/document"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	_ "github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	EQUIPMENT_UNKNOWN       = "Data not found"
+	EQUIPMENT_UNKNOWN_CAUSE = "DATA_NOT_FOUND"
+)
+
+const (
+	// EquipmentStatusCollName is the collection stored in the equipment_status table
+	EquipmentStatusCollName = "policyData.ues.eirData"
+	// TacModelCollName is the collection stored in the tac_models table
+	TacModelCollName = "policyData.ues.eirTacModels"
+	// PeiObservationCollName is the collection stored in the pei_observations table
+	PeiObservationCollName = "policyData.ues.eirPeiObservations"
+	// TacRuleCollName is the collection stored in the tac_rules table
+	TacRuleCollName = "policyData.ues.eirTacRules"
+	// ClonedPeiCollName is the collection stored in the cloned_peis table
+	ClonedPeiCollName = "policyData.ues.eirClonedPeis"
+	// AuditRecordCollName is the collection stored in the audit_records table
+	AuditRecordCollName = "policyData.ues.eirAuditRecords"
+)
+
+// table stores a collection in columns, any collection without table is stored as JSON documents in the documents table
+type table struct {
+	name string
+	// columns are the fields of the documents
+	columns []string
+	// timeColumns are stored as TIMESTAMP and exchanged as RFC 3339 strings
+	timeColumns []string
+	// jsonColumns are stored as JSON text, they hold the lists of the documents
+	jsonColumns []string
+	// intColumns are stored as BIGINT and exchanged as int64
+	intColumns []string
+}
+
+var tables = map[string]*table{
+	EquipmentStatusCollName: {
+		name:        "equipment_status",
+		columns:     []string{"pei", "supi", "gpsi", "equipment_status", "reason", "valid_from", "valid_until"},
+		timeColumns: []string{"valid_from", "valid_until"},
+	},
+	TacModelCollName: {
+		name:    "tac_models",
+		columns: []string{"tac", "brand", "model", "device_type"},
+	},
+	PeiObservationCollName: {
+		name:        "pei_observations",
+		columns:     []string{"pei", "supi", "observed_at"},
+		timeColumns: []string{"observed_at"},
+	},
+	TacRuleCollName: {
+		name:    "tac_rules",
+		columns: []string{"tac", "snr_start", "snr_end", "equipment_status", "reason"},
+	},
+	ClonedPeiCollName: {
+		name:        "cloned_peis",
+		columns:     []string{"pei", "supis", "detected_at"},
+		timeColumns: []string{"detected_at"},
+		jsonColumns: []string{"supis"},
+	},
+	// The time of the audit records is kept as text, it's hashed as it was written
+	AuditRecordCollName: {
+		name: "audit_records",
+		columns: []string{"sequence", "time", "event", "consumer_nf_id", "pei", "supi", "gpsi", "result",
+			"matched_rule", "model", "previous_hash", "hash"},
+		intColumns: []string{"sequence"},
+	},
+}
+
+// SqlDbConnector stores the equipment register in SQLite or PostgreSQL
+type SqlDbConnector struct {
+	*factory.Sql
+
+	db *sql.DB
+}
+
+func NewSqlDbConnector(sqlConfig *factory.Sql) (*SqlDbConnector, error) {
+	if sqlConfig == nil {
+		return nil, fmt.Errorf("the sql configuration is missing")
+	}
+	dialect, ok := dialects[sqlConfig.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sql driver: %s", sqlConfig.Driver)
+	}
+
+	db, err := sql.Open(dialect.driverName, sqlConfig.Dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql open: %+v", err)
+	}
+	if sqlConfig.Driver == factory.SqlDriverSqlite {
+		// SQLite serializes the writers, a single connection avoids the SQLITE_BUSY errors
+		db.SetMaxOpenConns(1)
+	}
+
+	if err = migrate(db, dialect); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			logger.DbLog.Warnf("sql close: %+v", closeErr)
+		}
+		return nil, err
+	}
+
+	return &SqlDbConnector{
+		Sql: sqlConfig,
+		db:  db,
+	}, nil
+}
+
+func dataNotFound() *models.ProblemDetails {
+	return &models.ProblemDetails{
+		Title:  EQUIPMENT_UNKNOWN,
+		Status: http.StatusNotFound,
+		Cause:  EQUIPMENT_UNKNOWN_CAUSE,
+	}
+}
+
+func (t *table) isColumn(field string) bool {
+	for _, column := range t.columns {
+		if field == column {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) isTimeColumn(column string) bool {
+	for _, timeColumn := range t.timeColumns {
+		if column == timeColumn {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) isJsonColumn(column string) bool {
+	for _, jsonColumn := range t.jsonColumns {
+		if column == jsonColumn {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) isIntColumn(column string) bool {
+	for _, intColumn := range t.intColumns {
+		if column == intColumn {
+			return true
+		}
+	}
+	return false
+}
+
+// intValue converts the number of a document, a JSON document holds its integers as float64
+func intValue(column string, value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("the %s field overflows a BIGINT", column)
+		}
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("the %s field isn't an integer", column)
+		}
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("the %s field isn't a number", column)
+	}
+}
+
+// columnValue converts a document value into a SQL value of the column
+func (t *table) columnValue(column string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if t.isTimeColumn(column) {
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC(), nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("the %s field isn't a RFC 3339 time: %+v", column, err)
+			}
+			return parsed.UTC(), nil
+		default:
+			return nil, fmt.Errorf("the %s field isn't a time", column)
+		}
+	}
+	if t.isIntColumn(column) {
+		return intValue(column, value)
+	}
+	if t.isJsonColumn(column) {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("the %s field isn't JSON: %+v", column, err)
+		}
+		return string(content), nil
+	}
+	if v, ok := value.(string); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("the %s field isn't a string", column)
+}
+
+// whereClause translates an equality filter of the documents
+func (t *table) whereClause(filter bson.M, args []interface{}) (string, []interface{}, error) {
+	var conditions []string
+	for field, value := range filter {
+		if !t.isColumn(field) {
+			return "", nil, fmt.Errorf("the %s field isn't a %s column", field, t.name)
+		}
+		if value == nil {
+			conditions = append(conditions, field+" IS NULL")
+			continue
+		}
+		sqlValue, err := t.columnValue(field, value)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, sqlValue)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan reads a row selected by selectStatement, the NULL columns are missing from the document
+func (t *table) scan(row scanner) (int64, map[string]interface{}, error) {
+	var id int64
+	destinations := []interface{}{&id}
+	for _, column := range t.columns {
+		if t.isTimeColumn(column) {
+			destinations = append(destinations, &sql.NullTime{})
+		} else if t.isIntColumn(column) {
+			destinations = append(destinations, &sql.NullInt64{})
+		} else {
+			destinations = append(destinations, &sql.NullString{})
+		}
+	}
+
+	if err := row.Scan(destinations...); err != nil {
+		return 0, nil, err
+	}
+
+	data := map[string]interface{}{}
+	for i, column := range t.columns {
+		switch value := destinations[i+1].(type) {
+		case *sql.NullTime:
+			if value.Valid {
+				data[column] = value.Time.UTC().Format(time.RFC3339)
+			}
+		case *sql.NullInt64:
+			if value.Valid {
+				data[column] = value.Int64
+			}
+		case *sql.NullString:
+			if !value.Valid {
+				continue
+			}
+			if !t.isJsonColumn(column) {
+				data[column] = value.String
+				continue
+			}
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(value.String), &decoded); err != nil {
+				return 0, nil, fmt.Errorf("the %s column isn't JSON: %+v", column, err)
+			}
+			data[column] = decoded
+		}
+	}
+	return id, data, nil
+}
+
+func (t *table) selectStatement() string {
+	return "SELECT id, " + strings.Join(t.columns, ", ") + " FROM " + t.name
+}
+
+// queryTable calls the callback on each row of the table matched by the filter, in the order of the column or
+// of the insertion without column
+func queryTable(querier querier, t *table, filter bson.M, orderBy string, limit bool,
+	callback func(id int64, data map[string]interface{}) error,
+) error {
+	where, args, err := t.whereClause(filter, nil)
+	if err != nil {
+		return err
+	}
+	if orderBy == "" {
+		orderBy = "id"
+	} else if !t.isColumn(orderBy) {
+		return fmt.Errorf("the %s field isn't a %s column", orderBy, t.name)
+	}
+	statement := t.selectStatement() + where + " ORDER BY " + orderBy
+	if limit {
+		statement += " LIMIT 1"
+	}
+
+	rows, err := querier.Query(statement, args...)
+	if err != nil {
+		return fmt.Errorf("sql query: %+v", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			logger.DbLog.Warnf("sql rows close: %+v", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		id, data, err := t.scan(rows)
+		if err != nil {
+			return fmt.Errorf("sql scan: %+v", err)
+		}
+		if err = callback(id, data); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("sql rows: %+v", err)
+	}
+	return nil
+}
+
+// queryDocuments calls the callback on each document of the collection matched by the filter
+func queryDocuments(querier querier, collName string, filter bson.M,
+	callback func(id int64, data map[string]interface{}) error,
+) error {
+	rows, err := querier.Query("SELECT id, data FROM documents WHERE collection = $1 ORDER BY id", collName)
+	if err != nil {
+		return fmt.Errorf("sql query: %+v", err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			logger.DbLog.Warnf("sql rows close: %+v", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var id int64
+		var content string
+		if err = rows.Scan(&id, &content); err != nil {
+			return fmt.Errorf("sql scan: %+v", err)
+		}
+		var data map[string]interface{}
+		if err = json.Unmarshal([]byte(content), &data); err != nil {
+			return fmt.Errorf("sql document %d: %+v", id, err)
+		}
+		if !document.Match(data, filter) {
+			continue
+		}
+		if err = callback(id, data); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("sql rows: %+v", err)
+	}
+	return nil
+}
+
+// errStop interrupts a query once the expected rows are found
+var errStop = fmt.Errorf("stop")
+
+// query dispatches the query to the table of the collection
+func query(querier querier, collName string, filter bson.M, first bool,
+	callback func(id int64, data map[string]interface{}) error,
+) error {
+	wrapped := callback
+	if first {
+		wrapped = func(id int64, data map[string]interface{}) error {
+			if err := callback(id, data); err != nil {
+				return err
+			}
+			return errStop
+		}
+	}
+
+	var err error
+	if t, ok := tables[collName]; ok {
+		err = queryTable(querier, t, filter, "", first, wrapped)
+	} else {
+		err = queryDocuments(querier, collName, filter, wrapped)
+	}
+	if err == errStop {
+		return nil
+	}
+	return err
+}
+
+// find returns the id and the document of the first match, the id is negative when nothing matches
+func find(querier querier, collName string, filter bson.M) (int64, map[string]interface{}, error) {
+	foundId := int64(-1)
+	var found map[string]interface{}
+	err := query(querier, collName, filter, true, func(id int64, data map[string]interface{}) error {
+		foundId, found = id, data
+		return nil
+	})
+	return foundId, found, err
+}
+
+func insert(querier querier, collName string, data map[string]interface{}) error {
+	t, ok := tables[collName]
+	if !ok {
+		content, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("sql document: %+v", err)
+		}
+		if _, err = querier.Exec("INSERT INTO documents (collection, data) VALUES ($1, $2)",
+			collName, string(content)); err != nil {
+			return fmt.Errorf("sql insert: %+v", err)
+		}
+		return nil
+	}
+
+	var columns, placeholders []string
+	var args []interface{}
+	for field, value := range data {
+		if !t.isColumn(field) {
+			return fmt.Errorf("the %s field isn't a %s column", field, t.name)
+		}
+		sqlValue, err := t.columnValue(field, value)
+		if err != nil {
+			return err
+		}
+		args = append(args, sqlValue)
+		columns = append(columns, field)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.name, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	if _, err := querier.Exec(statement, args...); err != nil {
+		return fmt.Errorf("sql insert: %+v", err)
+	}
+	return nil
+}
+
+// update merges the data into the row like a MongoDB $set
+func update(querier querier, collName string, id int64, original map[string]interface{},
+	data map[string]interface{},
+) error {
+	t, ok := tables[collName]
+	if !ok {
+		for field, value := range data {
+			original[field] = value
+		}
+		content, err := json.Marshal(original)
+		if err != nil {
+			return fmt.Errorf("sql document: %+v", err)
+		}
+		if _, err = querier.Exec("UPDATE documents SET data = $1 WHERE id = $2", string(content), id); err != nil {
+			return fmt.Errorf("sql update: %+v", err)
+		}
+		return nil
+	}
+
+	var assignments []string
+	var args []interface{}
+	for field, value := range data {
+		if !t.isColumn(field) {
+			return fmt.Errorf("the %s field isn't a %s column", field, t.name)
+		}
+		sqlValue, err := t.columnValue(field, value)
+		if err != nil {
+			return err
+		}
+		args = append(args, sqlValue)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	args = append(args, id)
+	statement := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d",
+		t.name, strings.Join(assignments, ", "), len(args))
+	if _, err := querier.Exec(statement, args...); err != nil {
+		return fmt.Errorf("sql update: %+v", err)
+	}
+	return nil
+}
+
+func remove(querier querier, collName string, id int64) error {
+	name := "documents"
+	if t, ok := tables[collName]; ok {
+		name = t.name
+	}
+	if _, err := querier.Exec("DELETE FROM "+name+" WHERE id = $1", id); err != nil {
+		return fmt.Errorf("sql delete: %+v", err)
+	}
+	return nil
+}
+
+// upsert updates the first document matched by the filter or inserts the data, it reports whether it existed
+func upsert(querier querier, collName string, filter bson.M, data map[string]interface{}) (bool, error) {
+	id, original, err := find(querier, collName, filter)
+	if err != nil {
+		return false, err
+	}
+	if id < 0 {
+		return false, insert(querier, collName, data)
+	}
+	return true, update(querier, collName, id, original, data)
+}
+
+// transaction runs the function in a transaction which is committed when the function succeeds
+func (s *SqlDbConnector) transaction(function func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("sql begin: %+v", err)
+	}
+	if err = function(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			logger.DbLog.Warnf("sql rollback: %+v", rollbackErr)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("sql commit: %+v", err)
+	}
+	return nil
+}
+
+func (s *SqlDbConnector) GetDataFromDB(collName string, filter bson.M) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	id, data, err := find(s.db, collName, filter)
+	if err != nil {
+		return nil, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	if id < 0 {
+		return nil, dataNotFound()
+	}
+	return data, nil
+}
+
+// GetDataFromDBWithArg ignores the collation strength, the SQL comparisons follow the database collation
+func (s *SqlDbConnector) GetDataFromDBWithArg(collName string, filter bson.M, strength int) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	return s.GetDataFromDB(collName, filter)
+}
+
+func (s *SqlDbConnector) PostDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	existed := false
+	err := s.transaction(func(tx *sql.Tx) error {
+		id, _, err := find(tx, collName, filter)
+		if err != nil {
+			return err
+		}
+		if id >= 0 {
+			existed = true
+			return nil
+		}
+		return insert(tx, collName, data)
+	})
+	if err != nil {
+		return false, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return existed, nil
+}
+
+func (s *SqlDbConnector) PutDataToDB(collName string, filter bson.M, data map[string]interface{}) (
+	bool, *models.ProblemDetails,
+) {
+	existed := false
+	err := s.transaction(func(tx *sql.Tx) error {
+		var err error
+		existed, err = upsert(tx, collName, filter, data)
+		return err
+	})
+	if err != nil {
+		return false, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return existed, nil
+}
+
+func (s *SqlDbConnector) DeleteDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	err := s.transaction(func(tx *sql.Tx) error {
+		id, _, err := find(tx, collName, filter)
+		if err != nil || id < 0 {
+			return err
+		}
+		return remove(tx, collName, id)
+	})
+	if err != nil {
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return nil
+}
+
+func (s *SqlDbConnector) PutManyDataToDB(collName string, filters []bson.M, data []map[string]interface{}) (
+	problemDetails *models.ProblemDetails,
+) {
+	if len(filters) != len(data) {
+		return openapi.ProblemDetailsSystemFailure(
+			fmt.Sprintf("PutManyDataToDB has %d filters for %d documents", len(filters), len(data)))
+	}
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		for i, filter := range filters {
+			if _, err := upsert(tx, collName, filter, data[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return nil
+}
+
+func (s *SqlDbConnector) DeleteManyDataFromDB(collName string, filter bson.M) *models.ProblemDetails {
+	err := s.transaction(func(tx *sql.Tx) error {
+		var ids []int64
+		err := query(tx, collName, filter, false, func(id int64, data map[string]interface{}) error {
+			ids = append(ids, id)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err = remove(tx, collName, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return nil
+}
+
+func (s *SqlDbConnector) GetManyDataFromDB(collName string, filter bson.M) (
+	[]map[string]interface{}, *models.ProblemDetails,
+) {
+	var matched []map[string]interface{}
+	err := query(s.db, collName, filter, false, func(id int64, data map[string]interface{}) error {
+		matched = append(matched, data)
+		return nil
+	})
+	if err != nil {
+		return nil, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	return matched, nil
+}
+
+func (s *SqlDbConnector) GetLastDataFromDB(collName string, field string) (
+	map[string]interface{}, *models.ProblemDetails,
+) {
+	var last map[string]interface{}
+	var err error
+	if t, ok := tables[collName]; ok {
+		last, err = lastRow(s.db, t, field)
+	} else {
+		var lastNumber float64
+		err = queryDocuments(s.db, collName, nil, func(id int64, data map[string]interface{}) error {
+			number, ok := document.ToFloat(data[field])
+			if ok && (last == nil || number > lastNumber) {
+				last, lastNumber = data, number
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	if last == nil {
+		return nil, dataNotFound()
+	}
+	return last, nil
+}
+
+// lastRow selects the row of the greatest value of the column through its index
+func lastRow(querier querier, t *table, column string) (map[string]interface{}, error) {
+	if !t.isIntColumn(column) {
+		return nil, fmt.Errorf("the %s field isn't a %s integer column", column, t.name)
+	}
+	row := querier.QueryRow(t.selectStatement() + " WHERE " + column + " IS NOT NULL ORDER BY " + column +
+		" DESC LIMIT 1")
+	_, data, err := t.scan(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sql scan: %+v", err)
+	}
+	return data, nil
+}
+
+func (s *SqlDbConnector) IterateDataFromDB(collName string, filter bson.M, sortField string,
+	callback func(data map[string]interface{}) error,
+) *models.ProblemDetails {
+	// The callback may write into the connector, so it's called on a snapshot once the rows are released
+	var matched []map[string]interface{}
+	snapshot := func(id int64, data map[string]interface{}) error {
+		matched = append(matched, data)
+		return nil
+	}
+	var err error
+	if t, ok := tables[collName]; ok && sortField != "" {
+		// The table is sorted by the database
+		err = queryTable(s.db, t, filter, sortField, false, snapshot)
+	} else {
+		err = query(s.db, collName, filter, false, snapshot)
+		if sortField != "" {
+			sort.SliceStable(matched, func(i, j int) bool {
+				return document.Less(matched[i][sortField], matched[j][sortField])
+			})
+		}
+	}
+	if err != nil {
+		return openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	for _, data := range matched {
+		if err := callback(data); err != nil {
+			return openapi.ProblemDetailsSystemFailure(err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *SqlDbConnector) Ping(ctx context.Context) *models.ProblemDetails {
+	if err := s.db.PingContext(ctx); err != nil {
+		return openapi.ProblemDetailsSystemFailure(fmt.Sprintf("sql ping: %+v", err))
+	}
+	return nil
+}
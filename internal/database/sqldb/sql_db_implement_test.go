@@ -0,0 +1,342 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func newSqliteDbConnector(t *testing.T, path string) *SqlDbConnector {
+	s, err := NewSqlDbConnector(&factory.Sql{
+		Driver: factory.SqlDriverSqlite,
+		Dsn:    path,
+	})
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		if err := s.db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return s
+}
+
+func TestSqlDbConnectorMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eir.db")
+
+	s := newSqliteDbConnector(t, path)
+	_, problemDetails := s.PostDataToDB(EquipmentStatusCollName, bson.M{"pei": "imei-42"},
+		map[string]interface{}{"pei": "imei-42", "equipment_status": "BLACKLISTED"})
+	require.Nil(t, problemDetails)
+
+	// The second connector must keep the schema and the data of the first one
+	s = newSqliteDbConnector(t, path)
+	var version int
+	require.Nil(t, s.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	data, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, bson.M{"pei": "imei-42"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, "BLACKLISTED", data["equipment_status"])
+}
+
+func TestSqlDbConnectorEquipmentStatus(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	problemDetails := s.PutManyDataToDB(EquipmentStatusCollName,
+		[]bson.M{
+			{"pei": "imei-012345678901234", "supi": nil},
+			{"pei": "imei-012345678901234", "supi": "imsi-208930000000001"},
+			{"pei": "imei-43", "gpsi": "msisdn-00043"},
+		},
+		[]map[string]interface{}{
+			{"pei": "imei-012345678901234", "equipment_status": "WHITELISTED"},
+			{
+				"pei": "imei-012345678901234", "supi": "imsi-208930000000001", "equipment_status": "BLACKLISTED",
+				"reason": "stolen", "valid_until": "2030-01-02T03:04:05Z",
+			},
+			{"pei": "imei-43", "gpsi": "msisdn-00043", "equipment_status": "BLACKLISTED"},
+		})
+	require.Nil(t, problemDetails)
+
+	tests := []struct {
+		name   string
+		filter bson.M
+		status string
+	}{
+		{
+			name:   "PEI",
+			filter: bson.M{"pei": "imei-012345678901234", "supi": nil},
+			status: "WHITELISTED",
+		},
+		{
+			name:   "PEI and SUPI",
+			filter: bson.M{"pei": "imei-012345678901234", "supi": "imsi-208930000000001"},
+			status: "BLACKLISTED",
+		},
+		{
+			name:   "PEI and GPSI",
+			filter: bson.M{"pei": "imei-43", "gpsi": "msisdn-00043"},
+			status: "BLACKLISTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, tt.filter)
+			require.Nil(t, problemDetails)
+			assert.Equal(t, tt.status, data["equipment_status"])
+		})
+	}
+
+	t.Run("Reason and validity", func(t *testing.T) {
+		data, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, bson.M{"supi": "imsi-208930000000001"})
+		require.Nil(t, problemDetails)
+		assert.Equal(t, "stolen", data["reason"])
+		assert.Equal(t, "2030-01-02T03:04:05Z", data["valid_until"])
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, bson.M{"pei": "imei-44"})
+		require.NotNil(t, problemDetails)
+		assert.Equal(t, EQUIPMENT_UNKNOWN_CAUSE, problemDetails.Cause)
+	})
+
+	t.Run("Unknown column", func(t *testing.T) {
+		_, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, bson.M{"imei": "imei-44"})
+		require.NotNil(t, problemDetails)
+		assert.Equal(t, "SYSTEM_FAILURE", problemDetails.Cause)
+	})
+
+	t.Run("Update and delete", func(t *testing.T) {
+		existed, problemDetails := s.PutDataToDB(EquipmentStatusCollName, bson.M{"pei": "imei-43"},
+			map[string]interface{}{"equipment_status": "WHITELISTED", "reason": nil})
+		require.Nil(t, problemDetails)
+		assert.True(t, existed)
+
+		data, problemDetails := s.GetDataFromDB(EquipmentStatusCollName, bson.M{"pei": "imei-43"})
+		require.Nil(t, problemDetails)
+		assert.Equal(t, map[string]interface{}{
+			"pei": "imei-43", "gpsi": "msisdn-00043", "equipment_status": "WHITELISTED",
+		}, data)
+
+		require.Nil(t, s.DeleteManyDataFromDB(EquipmentStatusCollName, bson.M{"pei": "imei-012345678901234"}))
+		all, problemDetails := s.GetManyDataFromDB(EquipmentStatusCollName, bson.M{})
+		require.Nil(t, problemDetails)
+		assert.Len(t, all, 1)
+	})
+}
+
+func TestSqlDbConnectorDocuments(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	existed, problemDetails := s.PostDataToDB("tacData", bson.M{"tac": "35209900"},
+		map[string]interface{}{"tac": "35209900", "brand": "Acme"})
+	require.Nil(t, problemDetails)
+	assert.False(t, existed)
+
+	existed, problemDetails = s.PutDataToDB("tacData", bson.M{"tac": "35209900"},
+		map[string]interface{}{"model": "Phone"})
+	require.Nil(t, problemDetails)
+	assert.True(t, existed)
+
+	data, problemDetails := s.GetDataFromDB("tacData", bson.M{"tac": "35209900"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, map[string]interface{}{"tac": "35209900", "brand": "Acme", "model": "Phone"}, data)
+
+	// A collection without table is sorted once its documents are read
+	_, problemDetails = s.PostDataToDB("tacData", bson.M{"tac": "35209901"},
+		map[string]interface{}{"tac": "35209901", "brand": "Acme"})
+	require.Nil(t, problemDetails)
+	_, problemDetails = s.PostDataToDB("tacData", bson.M{"tac": "35209800"},
+		map[string]interface{}{"tac": "35209800", "brand": "Acme"})
+	require.Nil(t, problemDetails)
+	var tacs []interface{}
+	problemDetails = s.IterateDataFromDB("tacData", bson.M{"brand": "Acme"}, "tac",
+		func(data map[string]interface{}) error {
+			tacs = append(tacs, data["tac"])
+			return nil
+		})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, []interface{}{"35209800", "35209900", "35209901"}, tacs)
+
+	require.Nil(t, s.DeleteDataFromDB("tacData", bson.M{"tac": "35209900"}))
+	_, problemDetails = s.GetDataFromDB("tacData", bson.M{"tac": "35209900"})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, EQUIPMENT_UNKNOWN_CAUSE, problemDetails.Cause)
+}
+
+func TestSqlDbConnectorTacModels(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	problemDetails := s.PutManyDataToDB(TacModelCollName,
+		[]bson.M{{"tac": "35209900"}, {"tac": "35209901"}, {"tac": "35209900"}},
+		[]map[string]interface{}{
+			{"tac": "35209900", "brand": "Acme", "model": "A-100", "device_type": nil},
+			{"tac": "35209901", "brand": "Acme", "model": "A-200", "device_type": "Tablet"},
+			{"tac": "35209900", "brand": "Acme", "model": "A-100 Pro", "device_type": "Smartphone"},
+		})
+	require.Nil(t, problemDetails)
+
+	data, problemDetails := s.GetDataFromDB(TacModelCollName, bson.M{"tac": "35209900"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, map[string]interface{}{
+		"tac": "35209900", "brand": "Acme", "model": "A-100 Pro", "device_type": "Smartphone",
+	}, data)
+
+	var count int
+	require.Nil(t, s.db.QueryRow("SELECT COUNT(*) FROM tac_models").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, problemDetails = s.GetDataFromDB(TacModelCollName, bson.M{"imei": "35209900"})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "SYSTEM_FAILURE", problemDetails.Cause)
+}
+
+func TestSqlDbConnectorTacRules(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	problemDetails := s.PutManyDataToDB(TacRuleCollName,
+		[]bson.M{
+			{"tac": "35209900", "snr_start": nil, "snr_end": nil},
+			{"tac": "35209900", "snr_start": "000100", "snr_end": "000199"},
+		},
+		[]map[string]interface{}{
+			{"tac": "35209900", "snr_start": nil, "snr_end": nil, "equipment_status": "GREYLISTED", "reason": nil},
+			{
+				"tac": "35209900", "snr_start": "000100", "snr_end": "000199", "equipment_status": "BLACKLISTED",
+				"reason": "stolen batch",
+			},
+		})
+	require.Nil(t, problemDetails)
+
+	rules, problemDetails := s.GetManyDataFromDB(TacRuleCollName, bson.M{"tac": "35209900"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, []map[string]interface{}{
+		{"tac": "35209900", "equipment_status": "GREYLISTED"},
+		{
+			"tac": "35209900", "snr_start": "000100", "snr_end": "000199", "equipment_status": "BLACKLISTED",
+			"reason": "stolen batch",
+		},
+	}, rules)
+}
+
+func TestSqlDbConnectorClonedPeis(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	_, problemDetails := s.PutDataToDB(ClonedPeiCollName, bson.M{"pei": "imei-42"}, map[string]interface{}{
+		"pei": "imei-42", "supis": []string{"imsi-1", "imsi-2"}, "detected_at": "2030-01-02T03:04:05Z",
+	})
+	require.Nil(t, problemDetails)
+
+	data, problemDetails := s.GetDataFromDB(ClonedPeiCollName, bson.M{"pei": "imei-42"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, map[string]interface{}{
+		"pei": "imei-42", "supis": []interface{}{"imsi-1", "imsi-2"}, "detected_at": "2030-01-02T03:04:05Z",
+	}, data)
+}
+
+func TestSqlDbConnectorAuditRecords(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+
+	_, problemDetails := s.GetLastDataFromDB(AuditRecordCollName, "sequence")
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, EQUIPMENT_UNKNOWN_CAUSE, problemDetails.Cause)
+
+	for _, record := range []map[string]interface{}{
+		{"sequence": int64(1), "time": "2030-01-02T03:04:05.123456789Z", "event": "check", "previous_hash": "",
+			"hash": "a1"},
+		// A JSON document holds its sequence as float64
+		{"sequence": float64(2), "time": "2030-01-02T03:04:06.1Z", "event": "check", "previous_hash": "a1",
+			"hash": "b2"},
+	} {
+		existed, problemDetails := s.PostDataToDB(AuditRecordCollName, bson.M{"sequence": record["sequence"]},
+			record)
+		require.Nil(t, problemDetails)
+		assert.False(t, existed)
+	}
+
+	// The time is kept as it was hashed
+	last, problemDetails := s.GetLastDataFromDB(AuditRecordCollName, "sequence")
+	require.Nil(t, problemDetails)
+	assert.Equal(t, map[string]interface{}{
+		"sequence": int64(2), "time": "2030-01-02T03:04:06.1Z", "event": "check", "previous_hash": "a1",
+		"hash": "b2",
+	}, last)
+
+	// A replica can't fork the chain at the same sequence
+	_, problemDetails = s.PostDataToDB(AuditRecordCollName, bson.M{"hash": "c3"}, map[string]interface{}{
+		"sequence": int64(2), "time": "2030-01-02T03:04:07Z", "event": "check", "previous_hash": "a1",
+		"hash": "c3",
+	})
+	assert.NotNil(t, problemDetails)
+
+	// The records are iterated in the order of their sequence, not of their insertion
+	_, problemDetails = s.PostDataToDB(AuditRecordCollName, bson.M{"sequence": int64(4)}, map[string]interface{}{
+		"sequence": int64(4), "time": "2030-01-02T03:04:08Z", "event": "check", "previous_hash": "c3",
+		"hash": "d4",
+	})
+	require.Nil(t, problemDetails)
+	_, problemDetails = s.PostDataToDB(AuditRecordCollName, bson.M{"sequence": int64(3)}, map[string]interface{}{
+		"sequence": int64(3), "time": "2030-01-02T03:04:07Z", "event": "check", "previous_hash": "b2",
+		"hash": "c3",
+	})
+	require.Nil(t, problemDetails)
+	var hashes []interface{}
+	problemDetails = s.IterateDataFromDB(AuditRecordCollName, bson.M{}, "sequence",
+		func(data map[string]interface{}) error {
+			hashes = append(hashes, data["hash"])
+			return nil
+		})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, []interface{}{"a1", "b2", "c3", "d4"}, hashes)
+
+	problemDetails = s.IterateDataFromDB(AuditRecordCollName, bson.M{}, "unknown",
+		func(data map[string]interface{}) error {
+			return nil
+		})
+	require.NotNil(t, problemDetails)
+	assert.Equal(t, "the unknown field isn't a audit_records column", problemDetails.Detail)
+}
+
+func TestSqlDbConnectorMigrationMovesDocuments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eir.db")
+
+	// A database of the version 3 stores the TAC rules as JSON documents
+	db, err := sql.Open("sqlite", path)
+	require.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL)")
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		tx, err := db.Begin()
+		require.Nil(t, err)
+		require.Nil(t, applyMigration(tx, dialects[factory.SqlDriverSqlite], i))
+		require.Nil(t, tx.Commit())
+	}
+	_, err = db.Exec("INSERT INTO documents (collection, data) VALUES ($1, $2)", TacRuleCollName,
+		`{"tac":"35209900","equipment_status":"BLACKLISTED","comment":"dropped"}`)
+	require.Nil(t, err)
+	require.Nil(t, db.Close())
+
+	s := newSqliteDbConnector(t, path)
+	data, problemDetails := s.GetDataFromDB(TacRuleCollName, bson.M{"tac": "35209900"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, map[string]interface{}{"tac": "35209900", "equipment_status": "BLACKLISTED"}, data)
+
+	var count int
+	require.Nil(t, s.db.QueryRow("SELECT COUNT(*) FROM documents").Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+func TestSqlDbConnectorPing(t *testing.T) {
+	s := newSqliteDbConnector(t, filepath.Join(t.TempDir(), "eir.db"))
+	require.Nil(t, s.Ping(context.Background()))
+
+	require.Nil(t, s.db.Close())
+	assert.NotNil(t, s.Ping(context.Background()))
+}
@@ -15,10 +15,12 @@ var (
 	UtilLog            *logrus.Entry
 	HttpLog            *logrus.Entry
 	ConsumerLog        *logrus.Entry
+	CallbackLog        *logrus.Entry
 	GinLog             *logrus.Entry
 	ProcLog            *logrus.Entry
 	SBILog             *logrus.Entry
 	DbLog              *logrus.Entry
+	MetricsLog         *logrus.Entry
 )
 
 func init() {
@@ -34,10 +36,12 @@ func init() {
 	CfgLog = NfLog.WithField(logger_util.FieldCategory, "CFG")
 	GinLog = NfLog.WithField(logger_util.FieldCategory, "GIN")
 	ConsumerLog = NfLog.WithField(logger_util.FieldCategory, "Consumer")
+	CallbackLog = NfLog.WithField(logger_util.FieldCategory, "Callback")
 	EquipmentStatusLog = NfLog.WithField(logger_util.FieldCategory, "EquipmentStatus")
 	ProcLog = NfLog.WithField(logger_util.FieldCategory, "Proc")
 	HttpLog = NfLog.WithField(logger_util.FieldCategory, "HTTP")
 	UtilLog = NfLog.WithField(logger_util.FieldCategory, "Util")
 	SBILog = NfLog.WithField(logger_util.FieldCategory, "SBI")
 	DbLog = NfLog.WithField(logger_util.FieldCategory, "DB")
+	MetricsLog = NfLog.WithField(logger_util.FieldCategory, "Metrics")
 }
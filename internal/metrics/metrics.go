@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "eir"
+
+// The results of the equipment status queries
+const (
+	ResultDefault  = "default"
+	ResultNotFound = "not-found"
+	ResultError    = "error"
+)
+
+var (
+	// Registry holds the EIR metrics, it's served on the metrics listener
+	Registry = prometheus.NewRegistry()
+
+	// EquipmentStatusQueries counts the equipment status queries by result, the status returned or
+	// default, not-found or error
+	EquipmentStatusQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "equipment_status_queries_total",
+		Help:      "The equipment status queries by result.",
+	}, []string{"result"})
+
+	// DatabaseDuration observes the duration of the DbConnector calls by operation
+	DatabaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "database_duration_seconds",
+		Help:      "The duration of the database calls by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation"})
+
+	// NrfRegistered is 1 while the EIR is registered to the NRF
+	NrfRegistered = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "nrf_registered",
+		Help:      "Whether the EIR is registered to the NRF.",
+	})
+
+	// HttpResponses counts the SBI responses by route and status code
+	HttpResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_responses_total",
+		Help:      "The SBI responses by route and status code.",
+	}, []string{"method", "route", "code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EquipmentStatusQueries,
+		DatabaseDuration,
+		NrfRegistered,
+		HttpResponses,
+	)
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Server serves the metrics on a listener separated from the SBI
+type Server struct {
+	httpServer *http.Server
+}
+
+func NewServer(config *factory.Metrics) (*Server, error) {
+	addr, err := netip.ParseAddr(config.BindingIP)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              netip.AddrPortFrom(addr, uint16(config.Port)).String(),
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}, nil
+}
+
+func (s *Server) Run(wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		logger.MetricsLog.Infof("Metrics server listens on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			logger.MetricsLog.Errorf("Metrics server failed: %+v", err)
+			return
+		}
+		logger.MetricsLog.Infof("Metrics server (listen on %s) stopped", s.httpServer.Addr)
+	}()
+}
+
+func (s *Server) Shutdown() {
+	const shutdownTimeout time.Duration = 2 * time.Second
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.MetricsLog.Errorf("Metrics server shutdown failed: %+v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	s, err := NewServer(&factory.Metrics{BindingIP: "127.0.0.1", Port: 9090, Path: "/metrics"})
+	require.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:9090", s.httpServer.Addr)
+
+	NrfRegistered.Set(1)
+	t.Cleanup(func() { NrfRegistered.Set(0) })
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/metrics", nil)
+	require.Nil(t, err)
+	rsp := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rsp, req)
+
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	assert.Contains(t, rsp.Body.String(), "eir_nrf_registered 1")
+}
+
+func TestNewServerWrongBindingIP(t *testing.T) {
+	_, err := NewServer(&factory.Metrics{BindingIP: "eir.local", Port: 9090, Path: "/metrics"})
+	assert.NotNil(t, err)
+}
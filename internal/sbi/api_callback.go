@@ -0,0 +1,64 @@
+package sbi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getCallbackRoutes() []Route {
+	return []Route{
+		{
+			"NfStatusNotify",
+			"POST",
+			"/nf-status-notify/:notificationId",
+			s.HandleNfStatusNotify,
+			// The notifications of the NRF aren't authorized by an access token, but by the notification ID
+			// of the subscription which only the NRF knows
+			"",
+		},
+	}
+}
+
+func (s *Server) HandleNfStatusNotify(c *gin.Context) {
+	logger.CallbackLog.Tracef("Handle NfStatusNotify")
+
+	if notificationId := c.Param("notificationId"); !s.eir.Context().IsAmfNotificationId(notificationId) {
+		problemDetail := models.ProblemDetails{
+			Title:  "The NF status notification has failed",
+			Status: http.StatusNotFound,
+			Detail: "The subscription [" + notificationId + "] isn't known",
+			Cause:  "SUBSCRIPTION_NOT_FOUND",
+		}
+		logger.CallbackLog.Warnf("The NF status notification of the unknown subscription [%s] from %s is refused",
+			notificationId, c.ClientIP())
+		c.JSON(http.StatusNotFound, problemDetail)
+		return
+	}
+
+	var notification models.NrfNfManagementNotificationData
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &notification)
+	}
+	if err == nil && notification.NfInstanceUri == "" {
+		err = errors.New("the NF instance URI is missing")
+	}
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "The NF status notification has failed",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Cause:  "INVALID_MSG_FORMAT",
+		}
+		logger.CallbackLog.Errorf("The NF status notification is malformed: %+v", err)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return
+	}
+
+	s.eir.Processor().NfStatusNotifyProcedure(c, notification)
+}
@@ -0,0 +1,65 @@
+package sbi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const notificationId = "7e0c5b52-2f6c-4c4d-9a0e-000000000001"
+
+func TestEIR_NfStatusNotify(t *testing.T) {
+	s := setupServerWithMemory(t, "policyData.ues.eirData: []\n", factory.Configuration{
+		DefaultStatus: factory.EquipmentStatusWhitelisted,
+	})
+	eirSelf := eir_context.GetSelf()
+	eirSelf.ReplaceAmfPeers(nil)
+	eirSelf.SetAmfNotificationId(notificationId)
+	t.Cleanup(func() {
+		eirSelf.ReplaceAmfPeers(nil)
+		eirSelf.SetAmfNotificationId("")
+	})
+
+	const amfUri = "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/5c0d1a2e-7f00-4b8e-9b1c-000000000001"
+	registered := `{"event": "NF_REGISTERED", "nfInstanceUri": "` + amfUri + `", "nfProfile": {
+		"nfInstanceId": "5c0d1a2e-7f00-4b8e-9b1c-000000000001", "nfType": "AMF", "nfStatus": "REGISTERED",
+		"fqdn": "amf.5gc.mnc093.mcc208.3gppnetwork.org"}}`
+	tests := []struct {
+		name           string
+		notificationId string
+		body           string
+		code           int
+		peers          int
+	}{
+		{"UnknownSubscription", "7e0c5b52-2f6c-4c4d-9a0e-000000000002", registered, http.StatusNotFound, 0},
+		{"Registered", notificationId, registered, http.StatusNoContent, 1},
+		{"OtherNfType", notificationId, `{"event": "NF_REGISTERED",
+			"nfInstanceUri": "http://127.0.0.10:8000/nf-instances/smf",
+			"nfProfile": {"nfInstanceId": "smf", "nfType": "SMF", "nfStatus": "REGISTERED"}}`, http.StatusNoContent, 1},
+		{"WithoutProfile", notificationId, `{"event": "NF_PROFILE_CHANGED", "nfInstanceUri": "` + amfUri + `"}`,
+			http.StatusBadRequest, 1},
+		{"WithoutUri", notificationId, `{"event": "NF_DEREGISTERED"}`, http.StatusBadRequest, 1},
+		{"Malformed", notificationId, `{"event": `, http.StatusBadRequest, 1},
+		{"Deregistered", notificationId, `{"event": "NF_DEREGISTERED", "nfInstanceUri": "` + amfUri + `"}`,
+			http.StatusNoContent, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+				factory.EirCallbackResUriPrefix+"/nf-status-notify/"+tt.notificationId, strings.NewReader(tt.body))
+			require.Nil(t, err)
+			rsp := httptest.NewRecorder()
+			s.router.ServeHTTP(rsp, req)
+
+			assert.Equal(t, tt.code, rsp.Code)
+			assert.Len(t, eirSelf.AmfPeers(), tt.peers)
+		})
+	}
+}
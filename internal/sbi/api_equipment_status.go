@@ -4,11 +4,29 @@ import (
 	"net/http"
 
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/internal/tac"
+	"github.com/adjivas/eir/internal/util"
 	"github.com/free5gc/openapi/models"
 	"github.com/gin-gonic/gin"
 )
 
-const maxURILength = 1024
+const (
+	maxURILength            = 1024
+	equipmentStatusCollName = "policyData.ues.eirData"
+	tacRuleCollName         = "policyData.ues.eirTacRules"
+	tacModelCollName        = tac.ModelCollName
+	peiObservationCollName  = "policyData.ues.eirPeiObservations"
+	clonedPeiCollName       = "policyData.ues.eirClonedPeis"
+)
+
+var lookupCollections = processor.LookupCollections{
+	EquipmentStatus: equipmentStatusCollName,
+	TacRules:        tacRuleCollName,
+	TacModels:       tacModelCollName,
+	PeiObservations: peiObservationCollName,
+	ClonedPeis:      clonedPeiCollName,
+}
 
 func (s *Server) getEquipmentStatusRoutes() []Route {
 	return []Route{
@@ -17,12 +35,14 @@ func (s *Server) getEquipmentStatusRoutes() []Route {
 			"GET",
 			"/",
 			Index,
+			models.ServiceName_N5G_EIR_EIC,
 		},
 		{
 			"EquipmentStatus",
 			"GET",
 			"/equipment-status",
 			s.HandleQueryEirEquipmentStatus,
+			models.ServiceName_N5G_EIR_EIC,
 		},
 	}
 }
@@ -53,7 +73,6 @@ func Index(c *gin.Context) {
 func (s *Server) HandleQueryEirEquipmentStatus(c *gin.Context) {
 	logger.EquipmentStatusLog.Tracef("Handle EirEquipmentStatus")
 
-	collName := "policyData.ues.eirData"
 	pei := c.Query("pei")
 	supi := c.DefaultQuery("supi", "")
 	gpsi := c.DefaultQuery("gpsi", "")
@@ -70,7 +89,20 @@ func (s *Server) HandleQueryEirEquipmentStatus(c *gin.Context) {
 		}
 		logger.HttpLog.Errorf("The PEI is missing")
 		c.JSON(http.StatusNotFound, problemDetail)
+	} else if _, err := util.ParsePei(pei); err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "The equipment identify checking has failed",
+			Status: http.StatusBadRequest,
+			Detail: "The PEI is malformed",
+			Cause:  "INVALID_QUERY_PARAM",
+			InvalidParams: []models.InvalidParam{{
+				Param:  "pei",
+				Reason: err.Error(),
+			}},
+		}
+		logger.HttpLog.Errorf("The PEI [%s] is malformed: %+v", pei, err)
+		c.JSON(http.StatusBadRequest, problemDetail)
 	} else {
-		s.eir.Processor().GetEirEquipmentStatusProcedure(c, collName, pei, supi, gpsi)
+		s.eir.Processor().GetEirEquipmentStatusProcedure(c, lookupCollections, pei, supi, gpsi)
 	}
 }
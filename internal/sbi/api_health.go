@@ -0,0 +1,99 @@
+package sbi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HealthStatusAlive    = "alive"
+	HealthStatusReady    = "ready"
+	HealthStatusNotReady = "not-ready"
+
+	ComponentStatusUp   = "up"
+	ComponentStatusDown = "down"
+
+	// databasePingTimeout bounds the ping of the readiness probe
+	databasePingTimeout = 2 * time.Second
+)
+
+// ComponentHealth is the status of a component of the EIR
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the body of the probes, the components are only reported by the readiness
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+func (s *Server) getHealthRoutes() []Route {
+	return []Route{
+		{
+			"Liveness",
+			"GET",
+			"/healthz",
+			s.HandleLiveness,
+			"",
+		},
+		{
+			"Readiness",
+			"GET",
+			"/readyz",
+			s.HandleReadiness,
+			"",
+		},
+	}
+}
+
+// HandleLiveness reports that the process serves its requests
+func (s *Server) HandleLiveness(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthReport{Status: HealthStatusAlive})
+}
+
+// HandleReadiness reports the database, the NRF registration and the SBI listener. The NRF registration only
+// makes the EIR not ready when the equipment identity checks are refused while it isn't registered.
+func (s *Server) HandleReadiness(c *gin.Context) {
+	ready := true
+	components := make(map[string]ComponentHealth, 3)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), databasePingTimeout)
+	defer cancel()
+	if problem := s.eir.Processor().DbConnector.Ping(ctx); problem != nil {
+		ready = false
+		components["database"] = ComponentHealth{Status: ComponentStatusDown, Detail: problem.Detail}
+	} else {
+		components["database"] = ComponentHealth{Status: ComponentStatusUp}
+	}
+
+	if s.eir.Context().IsRegistered() {
+		components["nrf"] = ComponentHealth{Status: ComponentStatusUp}
+	} else {
+		components["nrf"] = ComponentHealth{Status: ComponentStatusDown, Detail: "The EIR isn't registered to the NRF"}
+		nrfRegistration := s.eir.Config().Configuration.NrfRegistration
+		if nrfRegistration != nil && nrfRegistration.Unreachable == factory.NrfUnreachableRefuse {
+			ready = false
+		}
+	}
+
+	if s.listening.Load() {
+		components["sbi"] = ComponentHealth{Status: ComponentStatusUp}
+	} else {
+		ready = false
+		components["sbi"] = ComponentHealth{Status: ComponentStatusDown, Detail: "The SBI listener isn't bound"}
+	}
+
+	if !ready {
+		logger.SBILog.Warnf("The EIR isn't ready: %+v", components)
+		c.JSON(http.StatusServiceUnavailable, HealthReport{Status: HealthStatusNotReady, Components: components})
+		return
+	}
+	c.JSON(http.StatusOK, HealthReport{Status: HealthStatusReady, Components: components})
+}
@@ -0,0 +1,142 @@
+package sbi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, s *Server, uri string) (int, HealthReport) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, uri, nil)
+	require.Nil(t, err)
+	rsp := httptest.NewRecorder()
+	s.router.ServeHTTP(rsp, req)
+
+	var report HealthReport
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &report))
+	return rsp.Code, report
+}
+
+func TestEIR_Liveness(t *testing.T) {
+	s := setupServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	code, report := probe(t, s, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthReport{Status: HealthStatusAlive}, report)
+}
+
+func TestEIR_Readiness(t *testing.T) {
+	eirContext := eir_context.GetSelf()
+	defer eirContext.SetRegistered(false)
+
+	tests := []struct {
+		name        string
+		unreachable string
+		listening   bool
+		registered  bool
+		code        int
+		report      HealthReport
+	}{
+		{
+			name:       "Ready",
+			listening:  true,
+			registered: true,
+			code:       http.StatusOK,
+			report: HealthReport{Status: HealthStatusReady, Components: map[string]ComponentHealth{
+				"database": {Status: ComponentStatusUp},
+				"nrf":      {Status: ComponentStatusUp},
+				"sbi":      {Status: ComponentStatusUp},
+			}},
+		},
+		{
+			name:      "Served without NRF",
+			listening: true,
+			code:      http.StatusOK,
+			report: HealthReport{Status: HealthStatusReady, Components: map[string]ComponentHealth{
+				"database": {Status: ComponentStatusUp},
+				"nrf":      {Status: ComponentStatusDown, Detail: "The EIR isn't registered to the NRF"},
+				"sbi":      {Status: ComponentStatusUp},
+			}},
+		},
+		{
+			name:        "Refused without NRF",
+			unreachable: factory.NrfUnreachableRefuse,
+			listening:   true,
+			code:        http.StatusServiceUnavailable,
+			report: HealthReport{Status: HealthStatusNotReady, Components: map[string]ComponentHealth{
+				"database": {Status: ComponentStatusUp},
+				"nrf":      {Status: ComponentStatusDown, Detail: "The EIR isn't registered to the NRF"},
+				"sbi":      {Status: ComponentStatusUp},
+			}},
+		},
+		{
+			name:       "SBI listener unbound",
+			registered: true,
+			code:       http.StatusServiceUnavailable,
+			report: HealthReport{Status: HealthStatusNotReady, Components: map[string]ComponentHealth{
+				"database": {Status: ComponentStatusUp},
+				"nrf":      {Status: ComponentStatusUp},
+				"sbi":      {Status: ComponentStatusDown, Detail: "The SBI listener isn't bound"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration := factory.Configuration{}
+			if tt.unreachable != "" {
+				configuration.NrfRegistration = &factory.NrfRegistration{Unreachable: tt.unreachable}
+			}
+			s := setupServerWithMemory(t, `policyData.ues.eirData: []
+`, configuration)
+			s.listening.Store(tt.listening)
+			eirContext.SetRegistered(tt.registered)
+
+			code, report := probe(t, s, "/readyz")
+			require.Equal(t, tt.code, code)
+			assert.Equal(t, tt.report, report)
+		})
+	}
+}
+
+func TestEIR_ProbesListener(t *testing.T) {
+	s := setupServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+	assert.Nil(t, s.probeServer)
+
+	s = setupServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{Probes: &factory.Probes{BindingIP: "127.0.0.1", Port: 8081}})
+	require.NotNil(t, s.probeServer)
+	assert.Equal(t, "127.0.0.1:8081", s.probeServer.Addr)
+	assert.Nil(t, s.probeServer.TLSConfig)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go func() {
+		assert.Equal(t, http.ErrServerClosed, s.probeServer.Serve(listener))
+	}()
+	defer shutdownHttpServer(s.probeServer)
+
+	// The probes are served in plain HTTP, the other routes of the SBI aren't
+	for uri, code := range map[string]int{
+		"/healthz": http.StatusOK,
+		factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237": http.StatusNotFound,
+	} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+			"http://"+listener.Addr().String()+uri, nil)
+		require.Nil(t, err)
+		rsp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		require.Nil(t, rsp.Body.Close())
+		assert.Equal(t, code, rsp.StatusCode, uri)
+	}
+}
@@ -0,0 +1,197 @@
+package sbi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getProvisioningRoutes() []Route {
+	return []Route{
+		{
+			"CreateEquipmentStatus",
+			"POST",
+			"/equipment/:pei",
+			s.HandleCreateEquipmentStatus,
+			factory.EirProvServiceName,
+		},
+		{
+			"ReplaceEquipmentStatus",
+			"PUT",
+			"/equipment/:pei",
+			s.HandleReplaceEquipmentStatus,
+			factory.EirProvServiceName,
+		},
+		{
+			"ModifyEquipmentStatus",
+			"PATCH",
+			"/equipment/:pei",
+			s.HandleModifyEquipmentStatus,
+			factory.EirProvServiceName,
+		},
+		{
+			"DeleteEquipmentStatus",
+			"DELETE",
+			"/equipment/:pei",
+			s.HandleDeleteEquipmentStatus,
+			factory.EirProvServiceName,
+		},
+		{
+			"ListTacRules",
+			"GET",
+			"/tac-rules/:tac",
+			s.HandleListTacRules,
+			factory.EirProvServiceName,
+		},
+		{
+			"ReplaceTacRule",
+			"PUT",
+			"/tac-rules/:tac",
+			s.HandleReplaceTacRule,
+			factory.EirProvServiceName,
+		},
+		{
+			"DeleteTacRule",
+			"DELETE",
+			"/tac-rules/:tac",
+			s.HandleDeleteTacRule,
+			factory.EirProvServiceName,
+		},
+		{
+			"ListClonedPeis",
+			"GET",
+			"/cloned-peis",
+			s.HandleListClonedPeis,
+			factory.EirProvServiceName,
+		},
+		{
+			"DeleteClonedPei",
+			"DELETE",
+			"/cloned-peis/:pei",
+			s.HandleDeleteClonedPei,
+			factory.EirProvServiceName,
+		},
+	}
+}
+
+func bindEquipmentStatusRecord(c *gin.Context) (processor.EquipmentStatusRecord, bool) {
+	var record processor.EquipmentStatusRecord
+
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &record)
+	}
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "The equipment provisioning has failed",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Cause:  "INVALID_MSG_FORMAT",
+		}
+		logger.HttpLog.Errorf("The Equipment Status record is malformed: %+v", err)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return record, false
+	}
+	return record, true
+}
+
+func (s *Server) HandleCreateEquipmentStatus(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle CreateEquipmentStatus")
+
+	record, ok := bindEquipmentStatusRecord(c)
+	if !ok {
+		return
+	}
+	s.eir.Processor().CreateEquipmentStatusProcedure(c, equipmentStatusCollName,
+		c.Param("pei"), c.Query("supi"), c.Query("gpsi"), record)
+}
+
+func (s *Server) HandleReplaceEquipmentStatus(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle ReplaceEquipmentStatus")
+
+	record, ok := bindEquipmentStatusRecord(c)
+	if !ok {
+		return
+	}
+	s.eir.Processor().ReplaceEquipmentStatusProcedure(c, equipmentStatusCollName,
+		c.Param("pei"), c.Query("supi"), c.Query("gpsi"), record)
+}
+
+func (s *Server) HandleModifyEquipmentStatus(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle ModifyEquipmentStatus")
+
+	patchJSON, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "The equipment provisioning has failed",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Cause:  "INVALID_MSG_FORMAT",
+		}
+		logger.HttpLog.Errorf("The JSON Patch can't be read: %+v", err)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return
+	}
+	s.eir.Processor().ModifyEquipmentStatusProcedure(c, equipmentStatusCollName,
+		c.Param("pei"), c.Query("supi"), c.Query("gpsi"), patchJSON)
+}
+
+func (s *Server) HandleDeleteEquipmentStatus(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle DeleteEquipmentStatus")
+
+	s.eir.Processor().DeleteEquipmentStatusProcedure(c, equipmentStatusCollName,
+		c.Param("pei"), c.Query("supi"), c.Query("gpsi"))
+}
+
+func (s *Server) HandleListTacRules(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle ListTacRules")
+
+	s.eir.Processor().ListTacRulesProcedure(c, tacRuleCollName, c.Param("tac"))
+}
+
+func (s *Server) HandleReplaceTacRule(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle ReplaceTacRule")
+
+	var rule processor.TacRule
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &rule)
+	}
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "The equipment provisioning has failed",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Cause:  "INVALID_MSG_FORMAT",
+		}
+		logger.HttpLog.Errorf("The TAC rule is malformed: %+v", err)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return
+	}
+	s.eir.Processor().ReplaceTacRuleProcedure(c, tacRuleCollName,
+		c.Param("tac"), c.Query("snr_start"), c.Query("snr_end"), rule)
+}
+
+func (s *Server) HandleDeleteTacRule(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle DeleteTacRule")
+
+	s.eir.Processor().DeleteTacRuleProcedure(c, tacRuleCollName,
+		c.Param("tac"), c.Query("snr_start"), c.Query("snr_end"))
+}
+
+func (s *Server) HandleListClonedPeis(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle ListClonedPeis")
+
+	s.eir.Processor().ListClonedPeisProcedure(c, clonedPeiCollName)
+}
+
+func (s *Server) HandleDeleteClonedPei(c *gin.Context) {
+	logger.EquipmentStatusLog.Tracef("Handle DeleteClonedPei")
+
+	s.eir.Processor().DeleteClonedPeiProcedure(c, clonedPeiCollName, peiObservationCollName, c.Param("pei"))
+}
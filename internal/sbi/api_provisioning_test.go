@@ -0,0 +1,299 @@
+package sbi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	util_logger "github.com/free5gc/util/logger"
+	"github.com/free5gc/util/mongoapi"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func setupProvisioningHttpServer(t *testing.T) *gin.Engine {
+	router := util_logger.NewGinWithLogrus(logger.GinLog)
+	equipmentStatusGroup := router.Group(factory.EirDrResUriPrefix)
+	provisioningGroup := router.Group(factory.EirProvResUriPrefix)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	eir := NewMockEIR(ctrl)
+
+	factory.EirConfig = &factory.Config{
+		Configuration: &factory.Configuration{
+			DbConnectorType: "mongodb",
+			Mongodb:         &factory.Mongodb{},
+			Sbi: &factory.Sbi{
+				BindingIP: "127.0.0.1",
+				Port:      8000,
+			},
+		},
+	}
+	eir.EXPECT().
+		Config().
+		Return(factory.EirConfig).
+		AnyTimes()
+
+	processor := processor.NewProcessor(eir)
+	eir.EXPECT().Processor().Return(processor).AnyTimes()
+
+	s := NewServer(eir, "")
+	AddService(equipmentStatusGroup, s.getEquipmentStatusRoutes())
+	AddService(provisioningGroup, s.getProvisioningRoutes())
+
+	return router
+}
+
+func serveProvisioning(t *testing.T, server *gin.Engine, method string, uri string, body string) (
+	rsp *httptest.ResponseRecorder,
+) {
+	req, err := http.NewRequestWithContext(context.Background(), method, uri, strings.NewReader(body))
+	require.Nil(t, err)
+	rsp = httptest.NewRecorder()
+	server.ServeHTTP(rsp, req)
+	return rsp
+}
+
+func TestEIR_Provisioning_CreateEquipmentStatus(t *testing.T) {
+	server := setupProvisioningHttpServer(t)
+	setupMongoDB(t)
+
+	defer func() {
+		if err := mongoapi.Drop("policyData.ues.eirData"); err != nil {
+			panic(err)
+		}
+	}()
+
+	reqUri := factory.EirProvResUriPrefix + "/equipment/imei-012345678901237"
+	rsp := serveProvisioning(t, server, http.MethodPost, reqUri, `{"equipment_status": "BLACKLISTED"}`)
+	require.Equal(t, http.StatusCreated, rsp.Code)
+
+	record := processor.EquipmentStatusRecord{}
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &record))
+	require.Equal(t, processor.EquipmentStatusRecord{
+		Pei:             "imei-012345678901237",
+		EquipmentStatus: "BLACKLISTED",
+	}, record)
+
+	t.Run("Lookup", func(t *testing.T) {
+		reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
+		rsp := serveProvisioning(t, server, http.MethodGet, reqUri, "")
+		require.Equal(t, http.StatusOK, rsp.Code)
+		require.JSONEq(t, `{"status": "BLACKLISTED"}`, rsp.Body.String())
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		rsp := serveProvisioning(t, server, http.MethodPost, reqUri, `{"equipment_status": "WHITELISTED"}`)
+		require.Equal(t, http.StatusConflict, rsp.Code)
+	})
+}
+
+func TestEIR_Provisioning_CreateEquipmentStatus_InvalidStatus(t *testing.T) {
+	server := setupProvisioningHttpServer(t)
+	setupMongoDB(t)
+
+	defer func() {
+		if err := mongoapi.Drop("policyData.ues.eirData"); err != nil {
+			panic(err)
+		}
+	}()
+
+	reqUri := factory.EirProvResUriPrefix + "/equipment/imei-012345678901237"
+	rsp := serveProvisioning(t, server, http.MethodPost, reqUri, `{"equipment_status": "PINKLISTED"}`)
+	require.Equal(t, http.StatusBadRequest, rsp.Code)
+
+	problemDetail := models.ProblemDetails{}
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &problemDetail))
+	require.Equal(t, "MANDATORY_IE_INCORRECT", problemDetail.Cause)
+	require.Len(t, problemDetail.InvalidParams, 1)
+	require.Equal(t, "equipment_status", problemDetail.InvalidParams[0].Param)
+}
+
+func TestEIR_Provisioning_ReplaceEquipmentStatus(t *testing.T) {
+	server := setupProvisioningHttpServer(t)
+	setupMongoDB(t)
+
+	defer func() {
+		if err := mongoapi.Drop("policyData.ues.eirData"); err != nil {
+			panic(err)
+		}
+	}()
+
+	reqUri := factory.EirProvResUriPrefix + "/equipment/imei-012345678901237?supi=imsi-208930000000001"
+	rsp := serveProvisioning(t, server, http.MethodPut, reqUri, `{"equipment_status": "WHITELISTED"}`)
+	require.Equal(t, http.StatusCreated, rsp.Code)
+
+	rsp = serveProvisioning(t, server, http.MethodPut, reqUri, `{"equipment_status": "BLACKLISTED"}`)
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	data, err := mongoapi.RestfulAPIGetOne("policyData.ues.eirData", bson.M{"pei": "imei-012345678901237"})
+	require.Nil(t, err)
+	require.Equal(t, "imsi-208930000000001", data["supi"])
+	require.Equal(t, "BLACKLISTED", data["equipment_status"])
+}
+
+func TestEIR_Provisioning_ModifyEquipmentStatus(t *testing.T) {
+	server := setupProvisioningHttpServer(t)
+	setupMongoDB(t)
+
+	defer func() {
+		if err := mongoapi.Drop("policyData.ues.eirData"); err != nil {
+			panic(err)
+		}
+	}()
+
+	pei := bson.M{"pei": "imei-012345678901237", "equipment_status": "WHITELISTED"}
+	_, err := mongoapi.RestfulAPIPutOne("policyData.ues.eirData", bson.M{"pei": nil}, pei)
+	require.Nil(t, err)
+
+	reqUri := factory.EirProvResUriPrefix + "/equipment/imei-012345678901237"
+
+	t.Run("Replace", func(t *testing.T) {
+		patch := `[{"op": "replace", "path": "/equipment_status", "value": "BLACKLISTED"}]`
+		rsp := serveProvisioning(t, server, http.MethodPatch, reqUri, patch)
+		require.Equal(t, http.StatusOK, rsp.Code)
+		require.JSONEq(t, `{"pei": "imei-012345678901237", "equipment_status": "BLACKLISTED"}`, rsp.Body.String())
+	})
+
+	t.Run("InvalidStatus", func(t *testing.T) {
+		patch := `[{"op": "replace", "path": "/equipment_status", "value": "PINKLISTED"}]`
+		rsp := serveProvisioning(t, server, http.MethodPatch, reqUri, patch)
+		require.Equal(t, http.StatusBadRequest, rsp.Code)
+	})
+
+	t.Run("MalformedPatch", func(t *testing.T) {
+		rsp := serveProvisioning(t, server, http.MethodPatch, reqUri, `{"equipment_status": "BLACKLISTED"}`)
+		require.Equal(t, http.StatusBadRequest, rsp.Code)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		patch := `[{"op": "replace", "path": "/equipment_status", "value": "BLACKLISTED"}]`
+		rsp := serveProvisioning(t, server, http.MethodPatch,
+			factory.EirProvResUriPrefix+"/equipment/imei-490154203237518", patch)
+		require.Equal(t, http.StatusNotFound, rsp.Code)
+	})
+}
+
+func TestEIR_Provisioning_DeleteEquipmentStatus(t *testing.T) {
+	server := setupProvisioningHttpServer(t)
+	setupMongoDB(t)
+
+	defer func() {
+		if err := mongoapi.Drop("policyData.ues.eirData"); err != nil {
+			panic(err)
+		}
+	}()
+
+	pei := bson.M{"pei": "imei-012345678901237", "equipment_status": "BLACKLISTED"}
+	_, err := mongoapi.RestfulAPIPutOne("policyData.ues.eirData", bson.M{"pei": nil}, pei)
+	require.Nil(t, err)
+
+	reqUri := factory.EirProvResUriPrefix + "/equipment/imei-012345678901237"
+	rsp := serveProvisioning(t, server, http.MethodDelete, reqUri, "")
+	require.Equal(t, http.StatusNoContent, rsp.Code)
+
+	rsp = serveProvisioning(t, server, http.MethodDelete, reqUri, "")
+	require.Equal(t, http.StatusNotFound, rsp.Code)
+}
+
+func TestEIR_Provisioning_TacRules(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	reqUri := factory.EirProvResUriPrefix + "/tac-rules/49015420?snr_start=300000&snr_end=399999"
+	rsp := serveProvisioning(t, server, http.MethodPut, reqUri, `{"equipment_status": "BLACKLISTED"}`)
+	require.Equal(t, http.StatusCreated, rsp.Code)
+
+	rsp = serveProvisioning(t, server, http.MethodPut, reqUri, `{"equipment_status": "GREYLISTED"}`)
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	code, status := queryEquipmentStatus(t, server, "pei=imeisv-4901542032375101")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "GREYLISTED", status)
+
+	rsp = serveProvisioning(t, server, http.MethodGet, factory.EirProvResUriPrefix+"/tac-rules/49015420", "")
+	require.Equal(t, http.StatusOK, rsp.Code)
+	var rules []processor.TacRule
+	require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &rules))
+	require.Equal(t, []processor.TacRule{{
+		Tac:             "49015420",
+		SnrStart:        "300000",
+		SnrEnd:          "399999",
+		EquipmentStatus: "GREYLISTED",
+	}}, rules)
+
+	rsp = serveProvisioning(t, server, http.MethodDelete, reqUri, "")
+	require.Equal(t, http.StatusNoContent, rsp.Code)
+	rsp = serveProvisioning(t, server, http.MethodDelete, reqUri, "")
+	require.Equal(t, http.StatusNotFound, rsp.Code)
+}
+
+func TestEIR_Provisioning_TacRules_Invalid(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	tests := []struct {
+		name   string
+		reqUri string
+		param  string
+	}{
+		{
+			name:   "Short TAC",
+			reqUri: "/tac-rules/4901542",
+			param:  "tac",
+		},
+		{
+			name:   "Missing SNR range end",
+			reqUri: "/tac-rules/49015420?snr_start=300000",
+			param:  "snr_start",
+		},
+		{
+			name:   "Inverted SNR range",
+			reqUri: "/tac-rules/49015420?snr_start=399999&snr_end=300000",
+			param:  "snr_end",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := serveProvisioning(t, server, http.MethodPut, factory.EirProvResUriPrefix+tt.reqUri,
+				`{"equipment_status": "BLACKLISTED"}`)
+			require.Equal(t, http.StatusBadRequest, rsp.Code)
+
+			var problemDetails models.ProblemDetails
+			require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &problemDetails))
+			require.Len(t, problemDetails.InvalidParams, 1)
+			require.Equal(t, tt.param, problemDetails.InvalidParams[0].Param)
+		})
+	}
+}
+
+func TestEIR_Provisioning_CreateEquipmentStatus_InvalidParams(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	// The invalid parameters are listed in the order of the fields, whatever the request
+	for i := 0; i < 10; i++ {
+		rsp := serveProvisioning(t, server, http.MethodPost, factory.EirProvResUriPrefix+"/equipment/imei-012345678901234",
+			`{"equipment_status": "PINKLISTED", "valid_from": "today", "valid_until": "tomorrow"}`)
+		require.Equal(t, http.StatusBadRequest, rsp.Code)
+
+		var problemDetails models.ProblemDetails
+		require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &problemDetails))
+		var params []string
+		for _, invalidParam := range problemDetails.InvalidParams {
+			params = append(params, invalidParam.Param)
+		}
+		require.Equal(t, []string{"pei", "valid_from", "valid_until", "equipment_status"}, params)
+	}
+}
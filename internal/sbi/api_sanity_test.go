@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	eir_context "github.com/adjivas/eir/internal/context"
 	"github.com/adjivas/eir/internal/logger"
 	"github.com/adjivas/eir/internal/sbi/processor"
 	"github.com/adjivas/eir/internal/util"
@@ -96,13 +100,13 @@ func TestEIR_EquipmentStatus_FoundEquipmentStatus(t *testing.T) {
 	filter := bson.M{"pei": nil}
 	pei1 := bson.M{"pei": "imei-42", "equipment_status": "BLACKLISTED"}
 	pei2 := bson.M{"pei": "imei-43", "equipment_status": "BLACKLISTED"}
-	pei3 := bson.M{"pei": "imei-012345678901234", "equipment_status": "WHITELISTED"}
+	pei3 := bson.M{"pei": "imei-012345678901237", "equipment_status": "WHITELISTED"}
 	filters := []bson.M{filter, filter, filter}
 	peis := []map[string]interface{}{pei1, pei2, pei3}
 	err := mongoapi.RestfulAPIPutMany("policyData.ues.eirData", filters, peis)
 	assert.Nil(t, err)
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -136,15 +140,15 @@ func TestEIR_EquipmentStatus_FoundEquipmentStatus_WithSUPI(t *testing.T) {
 	}()
 
 	filter := bson.M{"pei": nil}
-	pei1 := bson.M{"pei": "imei-012345678901234", "supi": "imsi-208930000000001", "equipment_status": "BLACKLISTED"}
+	pei1 := bson.M{"pei": "imei-012345678901237", "supi": "imsi-208930000000001", "equipment_status": "BLACKLISTED"}
 	pei2 := bson.M{"pei": "imei-43", "supi": "imsi-208930123456789", "equipment_status": "BLACKLISTED"}
-	pei3 := bson.M{"pei": "imei-012345678901234", "supi": "imsi-208930123456789", "equipment_status": "WHITELISTED"}
+	pei3 := bson.M{"pei": "imei-012345678901237", "supi": "imsi-208930123456789", "equipment_status": "WHITELISTED"}
 	filters := []bson.M{filter, filter, filter}
 	peis := []map[string]interface{}{pei1, pei2, pei3}
 	err := mongoapi.RestfulAPIPutMany("policyData.ues.eirData", filters, peis)
 	assert.Nil(t, err)
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234&supi=imsi-208930123456789"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237&supi=imsi-208930123456789"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -179,14 +183,14 @@ func TestEIR_EquipmentStatus_FoundEquipmentStatus_WithGPSI(t *testing.T) {
 
 	filter := bson.M{"pei": nil}
 	pei1 := bson.M{"pei": "imei-42", "gpsi": "msisdn-00042", "equipment_status": "BLACKLISTED"}
-	pei2 := bson.M{"pei": "imei-012345678901234", "gpsi": "msisdn-00000", "equipment_status": "BLACKLISTED"}
-	pei3 := bson.M{"pei": "imei-012345678901234", "gpsi": "msisdn-12345", "equipment_status": "WHITELISTED"}
+	pei2 := bson.M{"pei": "imei-012345678901237", "gpsi": "msisdn-00000", "equipment_status": "BLACKLISTED"}
+	pei3 := bson.M{"pei": "imei-012345678901237", "gpsi": "msisdn-12345", "equipment_status": "WHITELISTED"}
 	filters := []bson.M{filter, filter, filter}
 	peis := []map[string]interface{}{pei1, pei2, pei3}
 	err := mongoapi.RestfulAPIPutMany("policyData.ues.eirData", filters, peis)
 	assert.Nil(t, err)
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234&gpsi=msisdn-12345"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237&gpsi=msisdn-12345"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -225,7 +229,7 @@ func TestEIR_EquipmentStatus_FoundEquipmentStatus_WithSUPI_GPSI(t *testing.T) {
 		"equipment_status": "BLACKLISTED",
 	}
 	pei2 := bson.M{
-		"pei": "imei-012345678901234", "supi": "imsi-012345678901234", "gpsi": "msisdn-12345",
+		"pei": "imei-012345678901237", "supi": "imsi-012345678901234", "gpsi": "msisdn-12345",
 		"equipment_status": "WHITELISTED",
 	}
 	pei3 := bson.M{
@@ -238,7 +242,7 @@ func TestEIR_EquipmentStatus_FoundEquipmentStatus_WithSUPI_GPSI(t *testing.T) {
 	assert.Nil(t, err)
 
 	reqUri := factory.EirDrResUriPrefix +
-		"/equipment-status?pei=imei-012345678901234&supi=imsi-012345678901234&gpsi=msisdn-12345"
+		"/equipment-status?pei=imei-012345678901237&supi=imsi-012345678901234&gpsi=msisdn-12345"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -271,7 +275,7 @@ func TestEIR_EquipmentStatus_NotFoundEquipmentStatus(t *testing.T) {
 		}
 	}()
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -347,7 +351,7 @@ func TestEIR_EquipmentStatus_NotFoundEquipmentStatus_WithDefaultStatusBlack(t *t
 		}
 	}()
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -380,7 +384,7 @@ func TestEIR_EquipmentStatus_NotFoundEquipmentStatus_WithDefaultStatusWhite(t *t
 		}
 	}()
 
-	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901234"
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
 
 	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
 	require.Nil(t, err)
@@ -474,3 +478,585 @@ func TestEIR_EquipmentStatus_WithoutDatabase(t *testing.T) {
 		require.Equal(t, http.StatusInternalServerError, rsp.Code)
 	})
 }
+
+func setupHttpServerWithMemory(t *testing.T, seedContent string, configuration factory.Configuration) *gin.Engine {
+	seed, err := os.CreateTemp(t.TempDir(), "*.yaml")
+	require.Nil(t, err)
+	_, err = seed.WriteString(seedContent)
+	require.Nil(t, err)
+	require.Nil(t, seed.Close())
+
+	configuration.DbConnectorType = "memory"
+	configuration.Memory = &factory.Memory{Seed: seed.Name()}
+	return setupHttpServerWithDatabase(t, configuration)
+}
+
+func setupHttpServerWithSqlite(t *testing.T, configuration factory.Configuration) *gin.Engine {
+	configuration.DbConnectorType = "sql"
+	configuration.Sql = &factory.Sql{
+		Driver: factory.SqlDriverSqlite,
+		Dsn:    filepath.Join(t.TempDir(), "eir.db"),
+	}
+	return setupHttpServerWithDatabase(t, configuration)
+}
+
+// setupHttpServerWithDatabase serves the equipment status and the provisioning routes without the middlewares
+func setupHttpServerWithDatabase(t *testing.T, configuration factory.Configuration) *gin.Engine {
+	router := util_logger.NewGinWithLogrus(logger.GinLog)
+	equipmentStatusGroup := router.Group(factory.EirDrResUriPrefix)
+	provisioningGroup := router.Group(factory.EirProvResUriPrefix)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	eir := NewMockEIR(ctrl)
+
+	configuration.Sbi = &factory.Sbi{
+		BindingIP: "127.0.0.1",
+		Port:      8000,
+	}
+	factory.EirConfig = &factory.Config{
+		Configuration: &configuration,
+	}
+	eir.EXPECT().
+		Config().
+		Return(factory.EirConfig).
+		AnyTimes()
+
+	processor := processor.NewProcessor(eir)
+	eir.EXPECT().Processor().Return(processor).AnyTimes()
+
+	s := NewServer(eir, "")
+	AddService(equipmentStatusGroup, s.getEquipmentStatusRoutes())
+	AddService(provisioningGroup, s.getProvisioningRoutes())
+
+	return router
+}
+
+// setupServerWithMemory creates the whole server, with the middlewares of its configuration
+func setupServerWithMemory(t *testing.T, seedContent string, configuration factory.Configuration) *Server {
+	seed, err := os.CreateTemp(t.TempDir(), "*.yaml")
+	require.Nil(t, err)
+	_, err = seed.WriteString(seedContent)
+	require.Nil(t, err)
+	require.Nil(t, seed.Close())
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	eir := NewMockEIR(ctrl)
+
+	configuration.DbConnectorType = "memory"
+	configuration.Memory = &factory.Memory{Seed: seed.Name()}
+	if configuration.Sbi == nil {
+		configuration.Sbi = &factory.Sbi{
+			BindingIP: "127.0.0.1",
+			Port:      8000,
+		}
+	}
+	factory.EirConfig = &factory.Config{
+		Configuration: &configuration,
+	}
+	eir.EXPECT().Config().Return(factory.EirConfig).AnyTimes()
+	eir.EXPECT().Context().Return(eir_context.GetSelf()).AnyTimes()
+
+	processor := processor.NewProcessor(eir)
+	eir.EXPECT().Processor().Return(processor).AnyTimes()
+
+	return NewServer(eir, "")
+}
+
+func queryEquipmentStatus(t *testing.T, server *gin.Engine, query string) (int, string) {
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?" + query
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
+	require.Nil(t, err)
+	rsp := httptest.NewRecorder()
+	server.ServeHTTP(rsp, req)
+
+	json_message := eir_api_service.EIREquipmentStatusGetResponse{}
+	err = json.Unmarshal(rsp.Body.Bytes(), &json_message)
+	assert.Nil(t, err)
+	return rsp.Code, json_message.Status
+}
+
+func TestEIR_EquipmentStatus_MemoryDatabase(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-012345678901237
+    supi: imsi-208930000000001
+    equipment_status: BLACKLISTED
+  - pei: imei-012345678901237
+    supi: imsi-208930123456789
+    equipment_status: WHITELISTED
+`, factory.Configuration{})
+
+	code, status := queryEquipmentStatus(t, server, "pei=imei-012345678901237&supi=imsi-208930123456789")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "WHITELISTED", status)
+}
+
+func TestEIR_EquipmentStatus_Greylisted(t *testing.T) {
+	notifications := make(chan processor.GreylistNotification, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification processor.GreylistNotification
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&notification))
+		notifications <- notification
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-012345678901237
+    equipment_status: GREYLISTED
+    reason: suspicious
+  - pei: imei-490154203237518
+    equipment_status: GREYLISTED
+  - pei: imei-437081612581614
+    equipment_status: GREYLISTED
+    reason: watched
+`, factory.Configuration{
+		GreylistPolicies: []*factory.GreylistPolicy{
+			{
+				Reason:        "suspicious",
+				Action:        "escalate",
+				EscalateAfter: 2,
+			},
+			{
+				Reason:          "watched",
+				Action:          "notify",
+				NotificationUri: receiver.URL,
+			},
+			{
+				Action: "log",
+			},
+		},
+	})
+
+	t.Run("Notify", func(t *testing.T) {
+		code, status := queryEquipmentStatus(t, server, "pei=imei-437081612581614")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "GREYLISTED", status)
+
+		select {
+		case notification := <-notifications:
+			require.Equal(t, "imei-437081612581614", notification.Pei)
+			require.Equal(t, "watched", notification.Reason)
+			require.Equal(t, 1, notification.Lookups)
+		case <-time.After(5 * time.Second):
+			t.Fatal("The grey-list notification isn't received")
+		}
+	})
+
+	t.Run("Log", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518")
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, "GREYLISTED", status)
+		}
+	})
+
+	t.Run("Escalate", func(t *testing.T) {
+		code, status := queryEquipmentStatus(t, server, "pei=imei-012345678901237")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "GREYLISTED", status)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imei-012345678901237")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "BLACKLISTED", status)
+
+		// The escalation is stored
+		code, status = queryEquipmentStatus(t, server, "pei=imei-012345678901237")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "BLACKLISTED", status)
+	})
+}
+
+func TestEIR_EquipmentStatus_GreylistedTacRuleEscalation(t *testing.T) {
+	configuration := factory.Configuration{
+		DefaultStatus: "WHITELISTED",
+		GreylistPolicies: []*factory.GreylistPolicy{
+			{
+				Action:        "escalate",
+				EscalateAfter: 2,
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *gin.Engine
+	}{
+		{
+			name: "Memory",
+			server: func(t *testing.T) *gin.Engine {
+				return setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, configuration)
+			},
+		},
+		{
+			name: "SQL",
+			server: func(t *testing.T) *gin.Engine {
+				return setupHttpServerWithSqlite(t, configuration)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.server(t)
+
+			reqUri := factory.EirProvResUriPrefix + "/tac-rules/49015420"
+			rsp := serveProvisioning(t, server, http.MethodPut, reqUri, `{"equipment_status": "GREYLISTED"}`)
+			require.Equal(t, http.StatusCreated, rsp.Code)
+
+			for _, want := range []string{"GREYLISTED", "BLACKLISTED", "BLACKLISTED"} {
+				code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518")
+				require.Equal(t, http.StatusOK, code)
+				require.Equal(t, want, status)
+			}
+
+			// The escalation of a TAC rule stores no record, the PEI follows the default once the rule is deleted
+			rsp = serveProvisioning(t, server, http.MethodDelete, reqUri, "")
+			require.Equal(t, http.StatusNoContent, rsp.Code)
+			code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518")
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, "WHITELISTED", status)
+		})
+	}
+}
+
+func TestEIR_EquipmentStatus_MalformedPei(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	for _, pei := range []string{"imei-012345678901234", "imei-42", "imeisv-43708161", "mac-00-1a-2b", "eui64-zz"} {
+		t.Run(pei, func(t *testing.T) {
+			reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=" + pei
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
+			require.Nil(t, err)
+			rsp := httptest.NewRecorder()
+			server.ServeHTTP(rsp, req)
+
+			json_message := models.ProblemDetails{}
+			err = json.Unmarshal(rsp.Body.Bytes(), &json_message)
+			require.Nil(t, err)
+
+			require.Equal(t, http.StatusBadRequest, rsp.Code)
+			require.Equal(t, "INVALID_QUERY_PARAM", json_message.Cause)
+			require.Len(t, json_message.InvalidParams, 1)
+			require.Equal(t, "pei", json_message.InvalidParams[0].Param)
+		})
+	}
+}
+
+func TestEIR_EquipmentStatus_ImeisvLookup(t *testing.T) {
+	seed := `policyData.ues.eirData:
+  - pei: imei-437081612581614
+    equipment_status: BLACKLISTED
+  - pei: imei-49015420323751
+    equipment_status: BLACKLISTED
+  - pei: imeisv-4370816125816199
+    equipment_status: WHITELISTED
+`
+
+	t.Run("ImeiFallback", func(t *testing.T) {
+		server := setupHttpServerWithMemory(t, seed, factory.Configuration{DefaultStatus: "WHITELISTED"})
+
+		// The exact IMEISV is preferred over its IMEI
+		code, status := queryEquipmentStatus(t, server, "pei=imeisv-4370816125816199")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imeisv-4370816125816151")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "BLACKLISTED", status)
+
+		// The 14 digits IMEI without the check digit
+		code, status = queryEquipmentStatus(t, server, "pei=imeisv-4901542032375101")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "BLACKLISTED", status)
+	})
+
+	t.Run("Exact", func(t *testing.T) {
+		server := setupHttpServerWithMemory(t, seed, factory.Configuration{
+			DefaultStatus:     "WHITELISTED",
+			PeiLookupStrategy: "exact",
+		})
+
+		code, status := queryEquipmentStatus(t, server, "pei=imeisv-4370816125816151")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+	})
+}
+
+func TestEIR_EquipmentStatus_TacRules(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-490154203237518
+    equipment_status: WHITELISTED
+policyData.ues.eirTacRules:
+  - tac: "49015420"
+    equipment_status: BLACKLISTED
+    reason: counterfeit
+  - tac: "49015420"
+    snr_start: "300000"
+    snr_end: "399999"
+    equipment_status: GREYLISTED
+  - tac: "49015420"
+    snr_start: "323000"
+    snr_end: "323999"
+    equipment_status: WHITELISTED
+`, factory.Configuration{DefaultStatus: "WHITELISTED"})
+
+	tests := []struct {
+		name   string
+		pei    string
+		status string
+	}{
+		{
+			name:   "Exact record",
+			pei:    "imei-490154203237518",
+			status: "WHITELISTED",
+		},
+		{
+			name:   "Whole TAC rule",
+			pei:    "imei-490154201000009",
+			status: "BLACKLISTED",
+		},
+		{
+			name:   "SNR range rule",
+			pei:    "imeisv-4901542031000001",
+			status: "GREYLISTED",
+		},
+		{
+			name:   "Narrowest SNR range rule",
+			pei:    "imei-" + "49015420323000" + string(util.LuhnCheckDigit("49015420323000")),
+			status: "WHITELISTED",
+		},
+		{
+			name:   "Default status",
+			pei:    "imei-437081612581614",
+			status: "WHITELISTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, status := queryEquipmentStatus(t, server, "pei="+tt.pei)
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, tt.status, status)
+		})
+	}
+}
+
+func TestEIR_EquipmentStatus_UnallocatedTac(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-437081612581614
+    equipment_status: WHITELISTED
+policyData.ues.eirTacModels:
+  - tac: "49015420"
+    brand: Acme
+    model: A-100
+    device_type: Smartphone
+`, factory.Configuration{
+		DefaultStatus:        "WHITELISTED",
+		UnallocatedTacStatus: "BLACKLISTED",
+	})
+
+	tests := []struct {
+		name   string
+		pei    string
+		status string
+	}{
+		{
+			name:   "Allocated TAC",
+			pei:    "imei-490154203237518",
+			status: "WHITELISTED",
+		},
+		{
+			name:   "Unallocated TAC",
+			pei:    "imeisv-3520990012345601",
+			status: "BLACKLISTED",
+		},
+		{
+			name:   "Unallocated TAC with a record",
+			pei:    "imei-437081612581614",
+			status: "WHITELISTED",
+		},
+		{
+			name:   "MAC address without TAC",
+			pei:    "mac-00-1a-2b-3c-4d-5e",
+			status: "WHITELISTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, status := queryEquipmentStatus(t, server, "pei="+tt.pei)
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, tt.status, status)
+		})
+	}
+}
+
+func TestEIR_EquipmentStatus_CloneDetection(t *testing.T) {
+	seed := `policyData.ues.eirData:
+  - pei: imei-490154203237518
+    supi: imsi-208930000000001
+    equipment_status: WHITELISTED
+`
+
+	t.Run("Cloned", func(t *testing.T) {
+		server := setupHttpServerWithMemory(t, seed, factory.Configuration{
+			DefaultStatus: "WHITELISTED",
+			CloneDetection: &factory.CloneDetection{
+				Window:       time.Hour,
+				MaxSupis:     1,
+				AutoGreylist: true,
+			},
+		})
+
+		code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000001")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000002")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "GREYLISTED", status)
+
+		// The flag stays on the PEI whatever the SUPI
+		code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000001")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "GREYLISTED", status)
+
+		rsp := serveProvisioning(t, server, http.MethodGet, factory.EirProvResUriPrefix+"/cloned-peis", "")
+		require.Equal(t, http.StatusOK, rsp.Code)
+		var clonedPeis []processor.ClonedPei
+		require.Nil(t, json.Unmarshal(rsp.Body.Bytes(), &clonedPeis))
+		require.Len(t, clonedPeis, 1)
+		require.Equal(t, "imei-490154203237518", clonedPeis[0].Pei)
+		require.Equal(t, []string{"imsi-208930000000001", "imsi-208930000000002"}, clonedPeis[0].Supis)
+
+		rsp = serveProvisioning(t, server, http.MethodDelete,
+			factory.EirProvResUriPrefix+"/cloned-peis/imei-490154203237518", "")
+		require.Equal(t, http.StatusNoContent, rsp.Code)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000001")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+	})
+
+	t.Run("Escalated", func(t *testing.T) {
+		server := setupHttpServerWithMemory(t, seed+`  - pei: imei-490154203237518
+    supi: imsi-208930000000002
+    equipment_status: WHITELISTED
+`, factory.Configuration{
+			GreylistPolicies: []*factory.GreylistPolicy{
+				{
+					Action:        "escalate",
+					EscalateAfter: 1,
+				},
+			},
+			CloneDetection: &factory.CloneDetection{
+				Window:       time.Hour,
+				MaxSupis:     1,
+				AutoGreylist: true,
+			},
+		})
+
+		code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000001")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000002")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "BLACKLISTED", status)
+
+		// The WHITELISTED record isn't overwritten by the escalation of the clone detection
+		rsp := serveProvisioning(t, server, http.MethodDelete,
+			factory.EirProvResUriPrefix+"/cloned-peis/imei-490154203237518", "")
+		require.Equal(t, http.StatusNoContent, rsp.Code)
+
+		code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000002")
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "WHITELISTED", status)
+	})
+
+	t.Run("Outside the window", func(t *testing.T) {
+		server := setupHttpServerWithMemory(t, seed, factory.Configuration{
+			DefaultStatus: "WHITELISTED",
+			CloneDetection: &factory.CloneDetection{
+				Window:       time.Nanosecond,
+				MaxSupis:     1,
+				AutoGreylist: true,
+			},
+		})
+
+		for _, supi := range []string{"imsi-208930000000001", "imsi-208930000000002", "imsi-208930000000003"} {
+			time.Sleep(time.Millisecond)
+			code, status := queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi="+supi)
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, "WHITELISTED", status)
+		}
+	})
+}
+
+func TestEIR_OAuth2_MissingToken(t *testing.T) {
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{})
+
+	eirContext := eir_context.GetSelf()
+	eirContext.SetOAuth2Required(true)
+	defer eirContext.SetOAuth2Required(false)
+
+	for _, reqUri := range []string{
+		factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237",
+		factory.EirProvResUriPrefix + "/cloned-peis",
+	} {
+		t.Run(reqUri, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
+			require.Nil(t, err)
+			rsp := httptest.NewRecorder()
+			server.ServeHTTP(rsp, req)
+
+			json_message := models.ProblemDetails{}
+			err = json.Unmarshal(rsp.Body.Bytes(), &json_message)
+			require.Nil(t, err)
+
+			require.Equal(t, http.StatusUnauthorized, rsp.Code)
+			require.Equal(t, "INVALID_TOKEN", json_message.Cause)
+			require.Equal(t, `Bearer error="invalid_token"`, rsp.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestEIR_EquipmentStatus_NrfUnreachableRefuse(t *testing.T) {
+	s := setupServerWithMemory(t, `policyData.ues.eirData: []
+`, factory.Configuration{
+		DefaultStatus:   factory.EquipmentStatusWhitelisted,
+		NrfRegistration: &factory.NrfRegistration{Unreachable: factory.NrfUnreachableRefuse},
+	})
+
+	eirContext := eir_context.GetSelf()
+	eirContext.SetRegistered(false)
+	defer eirContext.SetRegistered(false)
+
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237"
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
+	require.Nil(t, err)
+	rsp := httptest.NewRecorder()
+	s.router.ServeHTTP(rsp, req)
+
+	json_message := models.ProblemDetails{}
+	err = json.Unmarshal(rsp.Body.Bytes(), &json_message)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, rsp.Code)
+	require.Equal(t, "NF_NOT_REGISTERED", json_message.Cause)
+
+	// The provisioning is served before the registration
+	rsp = serveProvisioning(t, s.router, http.MethodGet, factory.EirProvResUriPrefix+"/cloned-peis", "")
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	eirContext.SetRegistered(true)
+	code, status := queryEquipmentStatus(t, s.router, "pei=imei-012345678901237")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, factory.EquipmentStatusWhitelisted, status)
+}
@@ -0,0 +1,146 @@
+package sbi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditRecords(t *testing.T, path string) []audit.Record {
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+
+	var records []audit.Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record audit.Record
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.Nil(t, scanner.Err())
+	return records
+}
+
+func TestEIR_Audit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-490154203237518
+    equipment_status: BLACKLISTED
+`, factory.Configuration{
+		DefaultStatus: factory.EquipmentStatusWhitelisted,
+		Audit: &factory.Audit{
+			Sink:    factory.AuditSinkFile,
+			Path:    path,
+			MaxSize: factory.AuditDefaultMaxSize,
+		},
+	})
+
+	reqUri := factory.EirDrResUriPrefix + "/equipment-status?pei=imeisv-4901542032375101"
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, reqUri, nil)
+	require.Nil(t, err)
+	req.Header.Set("3gpp-Sbi-NF-Peer-Info", "srcinst=8ff6c8f0-1c1a-4c2e-9a4e-1c3f5e2b7d10; dstinst=eir")
+	rsp := httptest.NewRecorder()
+	server.ServeHTTP(rsp, req)
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	code, status := queryEquipmentStatus(t, server, "pei=imei-437081612581614")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "WHITELISTED", status)
+
+	ruleUri := factory.EirProvResUriPrefix + "/tac-rules/49015420?snr_start=300000&snr_end=399999"
+	rsp = serveProvisioning(t, server, http.MethodPut, ruleUri, `{"equipment_status": "GREYLISTED"}`)
+	require.Equal(t, http.StatusCreated, rsp.Code)
+
+	records := readAuditRecords(t, path)
+	require.Len(t, records, 3)
+	assert.Equal(t, audit.EventCheck, records[0].Event)
+	assert.Equal(t, "8ff6c8f0-1c1a-4c2e-9a4e-1c3f5e2b7d10", records[0].ConsumerNfId)
+	assert.Equal(t, "imeisv-4901542032375101", records[0].Pei)
+	assert.Equal(t, "BLACKLISTED", records[0].Result)
+	assert.Equal(t, "record imei-490154203237518", records[0].MatchedRule)
+
+	assert.Equal(t, "imei-437081612581614", records[1].Pei)
+	assert.Equal(t, "WHITELISTED", records[1].Result)
+	assert.Equal(t, "default-status", records[1].MatchedRule)
+	assert.Equal(t, records[0].Hash, records[1].PreviousHash)
+
+	assert.Equal(t, audit.EventReplaceTacRule, records[2].Event)
+	assert.Equal(t, "GREYLISTED", records[2].Result)
+	assert.Equal(t, "tac-rule 49015420 [300000-399999]", records[2].MatchedRule)
+
+	sink, err := audit.NewFileSink(path, factory.AuditDefaultMaxSize)
+	require.Nil(t, err)
+	defer sink.Close()
+	verified, err := audit.Verify(sink)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), verified)
+}
+
+func TestEIR_AuditEscalationAndCloneDetection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	server := setupHttpServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-012345678901237
+    supi: imsi-208930000000001
+    equipment_status: GREYLISTED
+    reason: suspicious
+`, factory.Configuration{
+		DefaultStatus: factory.EquipmentStatusWhitelisted,
+		GreylistPolicies: []*factory.GreylistPolicy{
+			{
+				Action:        "escalate",
+				EscalateAfter: 1,
+			},
+		},
+		CloneDetection: &factory.CloneDetection{
+			Window:   time.Hour,
+			MaxSupis: 1,
+		},
+		Audit: &factory.Audit{
+			Sink:    factory.AuditSinkFile,
+			Path:    path,
+			MaxSize: factory.AuditDefaultMaxSize,
+		},
+	})
+
+	code, status := queryEquipmentStatus(t, server, "pei=imei-012345678901237&supi=imsi-208930000000001")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "BLACKLISTED", status)
+
+	code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000001")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "WHITELISTED", status)
+	code, status = queryEquipmentStatus(t, server, "pei=imei-490154203237518&supi=imsi-208930000000002")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "WHITELISTED", status)
+
+	records := readAuditRecords(t, path)
+	require.Len(t, records, 5)
+	assert.Equal(t, audit.EventEscalateGreylisted, records[0].Event)
+	assert.Equal(t, "imei-012345678901237", records[0].Pei)
+	assert.Equal(t, "imsi-208930000000001", records[0].Supi)
+	assert.Equal(t, "BLACKLISTED", records[0].Result)
+	assert.Equal(t, "greylist-policy after 1 lookups", records[0].MatchedRule)
+	assert.Equal(t, audit.EventCheck, records[1].Event)
+	assert.Equal(t, "BLACKLISTED", records[1].Result)
+
+	assert.Equal(t, audit.EventCheck, records[2].Event)
+	assert.Equal(t, audit.EventDetectClonedPei, records[3].Event)
+	assert.Equal(t, "imei-490154203237518", records[3].Pei)
+	assert.Equal(t, "imsi-208930000000002", records[3].Supi)
+	assert.Equal(t, audit.ResultCloned, records[3].Result)
+	assert.Equal(t, "clone-detection 2 SUPIs in 1h0m0s", records[3].MatchedRule)
+	assert.Equal(t, audit.EventCheck, records[4].Event)
+	assert.Equal(t, "WHITELISTED", records[4].Result)
+}
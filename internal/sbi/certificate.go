@@ -0,0 +1,134 @@
+package sbi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/adjivas/eir/internal/logger"
+)
+
+const (
+	// certificateWatchInterval is the period of the checks of the certificate files modification
+	certificateWatchInterval = 10 * time.Second
+	// certificateExpiryWarning is how long before its expiry a certificate is warned about
+	certificateExpiryWarning = 30 * 24 * time.Hour
+)
+
+// certificateReloader serves the SBI certificate, it's swapped when its files are modified or on SIGHUP
+type certificateReloader struct {
+	pemPath string
+	keyPath string
+
+	certificate atomic.Pointer[tls.Certificate]
+	modTimes    [2]time.Time
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+func newCertificateReloader(pemPath string, keyPath string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		pemPath: pemPath,
+		keyPath: keyPath,
+		done:    make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is the tls.Config callback serving the last certificate loaded
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate.Load(), nil
+}
+
+func (r *certificateReloader) modificationTimes() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, path := range []string{r.pemPath, r.keyPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// reload loads the certificate, the previous one is kept when the files can't be loaded
+func (r *certificateReloader) reload() error {
+	modTimes, err := r.modificationTimes()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.pemPath, r.keyPath)
+	if err != nil {
+		return fmt.Errorf("the certificate [%s] can't be loaded: %+v", r.pemPath, err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("the certificate [%s] is malformed: %+v", r.pemPath, err)
+	}
+	certificate.Leaf = leaf
+
+	r.certificate.Store(&certificate)
+	r.modTimes = modTimes
+	logger.SBILog.Infof("The certificate [%s] is loaded, it expires on %s", r.pemPath,
+		leaf.NotAfter.Format(time.RFC3339))
+	if remaining := time.Until(leaf.NotAfter); remaining < certificateExpiryWarning {
+		logger.SBILog.Warnf("The certificate [%s] expires in %s", r.pemPath, remaining.Round(time.Hour))
+	}
+	return nil
+}
+
+// reloadIfModified reloads the certificate when one of its files was modified since the last load
+func (r *certificateReloader) reloadIfModified() {
+	modTimes, err := r.modificationTimes()
+	if err != nil {
+		logger.SBILog.Warnf("The certificate files can't be checked: %+v", err)
+		return
+	}
+	if modTimes == r.modTimes {
+		return
+	}
+	// A broken certificate is retried on the next modification only
+	r.modTimes = modTimes
+	if err = r.reload(); err != nil {
+		logger.SBILog.Errorf("The certificate isn't reloaded: %+v", err)
+	}
+}
+
+// watch reloads the certificate when its files are modified or on SIGHUP, until stop
+func (r *certificateReloader) watch() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	ticker := time.NewTicker(certificateWatchInterval)
+
+	go func() {
+		defer signal.Stop(sighup)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadIfModified()
+			case <-sighup:
+				logger.SBILog.Infoln("SIGHUP received, the certificate is reloaded")
+				if err := r.reload(); err != nil {
+					logger.SBILog.Errorf("The certificate isn't reloaded: %+v", err)
+				}
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+func (r *certificateReloader) stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
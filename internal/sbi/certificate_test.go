@@ -0,0 +1,70 @@
+package sbi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCertificate(t *testing.T, pemPath string, keyPath string, serial int64, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "eir.5gc.mnc093.mcc208.3gppnetwork.org"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	require.Nil(t, os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.Nil(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	// The modification time must change even on a coarse file system
+	require.Nil(t, os.Chtimes(pemPath, modTime, modTime))
+	require.Nil(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+func TestCertificateReloader(t *testing.T) {
+	pemPath := filepath.Join(t.TempDir(), "eir.pem")
+	keyPath := filepath.Join(t.TempDir(), "eir.key")
+	now := time.Now()
+	writeCertificate(t, pemPath, keyPath, 1, now)
+
+	reloader, err := newCertificateReloader(pemPath, keyPath)
+	require.Nil(t, err)
+	certificate, err := reloader.GetCertificate(nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), certificate.Leaf.SerialNumber.Int64())
+
+	// The unmodified files aren't reloaded
+	reloader.reloadIfModified()
+	assert.Same(t, certificate, reloader.certificate.Load())
+
+	writeCertificate(t, pemPath, keyPath, 2, now.Add(time.Minute))
+	reloader.reloadIfModified()
+	certificate, err = reloader.GetCertificate(nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), certificate.Leaf.SerialNumber.Int64())
+
+	// A broken certificate keeps the previous one
+	require.Nil(t, os.WriteFile(pemPath, []byte("broken"), 0o600))
+	require.Nil(t, os.Chtimes(pemPath, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+	reloader.reloadIfModified()
+	certificate, err = reloader.GetCertificate(nil)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), certificate.Leaf.SerialNumber.Int64())
+}
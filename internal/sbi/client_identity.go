@@ -0,0 +1,117 @@
+package sbi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+// configureClientAuth verifies the client certificates against the client CA bundle of the TLS configuration
+func configureClientAuth(server *http.Server, tlsConfig *factory.Tls) error {
+	// The http.Server logs the rejected handshakes through its ErrorLog
+	server.ErrorLog = log.New(sbiLogWriter{}, "", 0)
+
+	if tlsConfig == nil || tlsConfig.ClientCa == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(tlsConfig.ClientCa)
+	if err != nil {
+		return fmt.Errorf("the client CA bundle can't be read: %+v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return fmt.Errorf("the client CA bundle [%s] has no certificate", tlsConfig.ClientCa)
+	}
+
+	if server.TLSConfig == nil {
+		server.TLSConfig = &tls.Config{}
+	}
+	server.TLSConfig.ClientCAs = pool
+	server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if tlsConfig.RequireClientCert {
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	logger.SBILog.Infof("The client certificates are verified by [%s] (required: %t)",
+		tlsConfig.ClientCa, tlsConfig.RequireClientCert)
+	return nil
+}
+
+// sbiLogWriter writes the errors of the http.Server, as the TLS handshake errors, into the SBILog
+type sbiLogWriter struct{}
+
+func (sbiLogWriter) Write(p []byte) (int, error) {
+	logger.SBILog.Warnln(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// clientIdentities returns the identities of a client certificate: its DNS and URI SANs,
+// the NF instance ID of its urn:uuid URI SAN and its CN
+func clientIdentities(certificate *x509.Certificate) []string {
+	identities := append([]string{}, certificate.DNSNames...)
+	for _, uri := range certificate.URIs {
+		identities = append(identities, uri.String())
+		if nfInstanceId, found := strings.CutPrefix(uri.String(), "urn:uuid:"); found {
+			identities = append(identities, nfInstanceId)
+		}
+	}
+	if certificate.Subject.CommonName != "" {
+		identities = append(identities, certificate.Subject.CommonName)
+	}
+	return identities
+}
+
+// clientIdentityCheck refuses the requests of the clients whose certificate identity isn't allowed to call the route,
+// the AMF peers are allowed to call every route of the group with allowAmfPeers. It's nil without allow-list
+func clientIdentityCheck(group *gin.RouterGroup, routes []Route, allowList []*factory.ClientIdentity,
+	allowAmfPeers bool,
+) gin.HandlerFunc {
+	if len(allowList) == 0 && !allowAmfPeers {
+		return nil
+	}
+
+	routeNames := make(map[string]string)
+	for _, route := range routes {
+		routeNames[route.Method+" "+group.BasePath()+route.Pattern] = route.Name
+	}
+
+	return func(c *gin.Context) {
+		routeName := routeNames[c.Request.Method+" "+c.FullPath()]
+
+		var identities []string
+		if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+			identities = clientIdentities(c.Request.TLS.PeerCertificates[0])
+		}
+		if allowAmfPeers && len(identities) > 0 && eir_context.GetSelf().IsAmfPeer(identities) {
+			c.Next()
+			return
+		}
+		for _, client := range allowList {
+			if slices.Contains(identities, client.Identity) &&
+				(slices.Contains(client.Routes, routeName) || slices.Contains(client.Routes, factory.AllRoutes)) {
+				c.Next()
+				return
+			}
+		}
+
+		logger.SBILog.Warnf("The client %v from %s isn't allowed to call %s", identities, c.ClientIP(), routeName)
+		problemDetail := models.ProblemDetails{
+			Title:  "Forbidden",
+			Status: http.StatusForbidden,
+			Detail: "The client certificate isn't allowed to call " + routeName,
+			Cause:  "CLIENT_NOT_ALLOWED",
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, problemDetail)
+	}
+}
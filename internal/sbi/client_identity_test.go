@@ -0,0 +1,131 @@
+package sbi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeClientCa(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "5GC CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+func TestEIR_ClientIdentity(t *testing.T) {
+	seed, err := os.CreateTemp(t.TempDir(), "*.yaml")
+	require.Nil(t, err)
+	_, err = seed.WriteString("policyData.ues.eirData: []\n")
+	require.Nil(t, err)
+	require.Nil(t, seed.Close())
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	eir := NewMockEIR(ctrl)
+
+	factory.EirConfig = &factory.Config{
+		Configuration: &factory.Configuration{
+			DbConnectorType: "memory",
+			Memory:          &factory.Memory{Seed: seed.Name()},
+			DefaultStatus:   factory.EquipmentStatusWhitelisted,
+			Sbi: &factory.Sbi{
+				BindingIP: "127.0.0.1",
+				Port:      8000,
+				Tls: &factory.Tls{
+					Pem:      "cert/eir.pem",
+					Key:      "cert/eir.key",
+					ClientCa: writeClientCa(t),
+					ClientAllowList: []*factory.ClientIdentity{
+						{Identity: "amf.5gc.mnc093.mcc208.3gppnetwork.org", Routes: []string{"EquipmentStatus"}},
+						{Identity: "5c0d1a2e-7f00-4b8e-9b1c-000000000001", Routes: []string{"EquipmentStatus"}},
+						{Identity: "operator", Routes: []string{factory.AllRoutes}},
+					},
+					AllowAmfPeers: true,
+				},
+			},
+		},
+	}
+	eir.EXPECT().Config().Return(factory.EirConfig).AnyTimes()
+	processor := processor.NewProcessor(eir)
+	eir.EXPECT().Processor().Return(processor).AnyTimes()
+
+	s := NewServer(eir, "")
+	require.NotNil(t, s.httpServer.TLSConfig)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, s.httpServer.TLSConfig.ClientAuth)
+
+	amf := &x509.Certificate{DNSNames: []string{"amf.5gc.mnc093.mcc208.3gppnetwork.org"}}
+	nfInstance := &x509.Certificate{URIs: []*url.URL{{Scheme: "urn", Opaque: "uuid:5c0d1a2e-7f00-4b8e-9b1c-000000000001"}}}
+	operator := &x509.Certificate{Subject: pkix.Name{CommonName: "operator"}}
+	// The AMF peer isn't in the allow-list, it's discovered from the NRF
+	amfPeer := &x509.Certificate{DNSNames: []string{"amf2.5gc.mnc093.mcc208.3gppnetwork.org"}}
+	eirSelf := eir_context.GetSelf()
+	eirSelf.ReplaceAmfPeers([]eir_context.AmfPeer{{
+		NfInstanceId: "5c0d1a2e-7f00-4b8e-9b1c-000000000002",
+		Fqdn:         "amf2.5gc.mnc093.mcc208.3gppnetwork.org",
+	}})
+	t.Cleanup(func() { eirSelf.ReplaceAmfPeers(nil) })
+
+	tests := []struct {
+		name        string
+		certificate *x509.Certificate
+		reqUri      string
+		code        int
+	}{
+		{"AmfLookup", amf, factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237", http.StatusOK},
+		{"AmfProvisioning", amf, factory.EirProvResUriPrefix + "/cloned-peis", http.StatusForbidden},
+		{"NfInstanceLookup", nfInstance, factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237",
+			http.StatusOK},
+		{"AmfPeerLookup", amfPeer, factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237",
+			http.StatusOK},
+		{"AmfPeerProvisioning", amfPeer, factory.EirProvResUriPrefix + "/cloned-peis", http.StatusForbidden},
+		{"OperatorProvisioning", operator, factory.EirProvResUriPrefix + "/cloned-peis", http.StatusOK},
+		{"WithoutCertificate", nil, factory.EirDrResUriPrefix + "/equipment-status?pei=imei-012345678901237",
+			http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tt.reqUri, nil)
+			require.Nil(t, err)
+			if tt.certificate != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.certificate}}
+			}
+			rsp := httptest.NewRecorder()
+			s.router.ServeHTTP(rsp, req)
+
+			assert.Equal(t, tt.code, rsp.Code)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package consumer
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/adjivas/eir/pkg/factory"
+)
+
+// registrationBackoff is the exponential backoff with jitter of the registration retries
+type registrationBackoff struct {
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+func newRegistrationBackoff(config *factory.NrfRegistration) *registrationBackoff {
+	b := &registrationBackoff{
+		delay:    factory.NrfRegistrationDefaultInitialBackoff,
+		maxDelay: factory.NrfRegistrationDefaultMaxBackoff,
+	}
+	if config != nil {
+		if config.InitialBackoff > 0 {
+			b.delay = config.InitialBackoff
+		}
+		if config.MaxBackoff > 0 {
+			b.maxDelay = config.MaxBackoff
+		}
+	}
+	return b
+}
+
+// next returns a delay between the half and the whole of the current delay, which is then doubled up to its maximum
+func (b *registrationBackoff) next() time.Duration {
+	delay := b.delay/2 + time.Duration(rand.Int63n(int64(b.delay/2)+1))
+	b.delay = min(2*b.delay, b.maxDelay)
+	return delay
+}
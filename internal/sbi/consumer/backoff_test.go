@@ -0,0 +1,29 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrationBackoff(t *testing.T) {
+	backoff := newRegistrationBackoff(&factory.NrfRegistration{
+		InitialBackoff: time.Second,
+		MaxBackoff:     4 * time.Second,
+	})
+
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		next := backoff.next()
+		assert.GreaterOrEqual(t, next, delay/2)
+		assert.LessOrEqual(t, next, delay)
+	}
+}
+
+func TestRegistrationBackoffDefault(t *testing.T) {
+	backoff := newRegistrationBackoff(nil)
+
+	assert.Equal(t, factory.NrfRegistrationDefaultInitialBackoff, backoff.delay)
+	assert.Equal(t, factory.NrfRegistrationDefaultMaxBackoff, backoff.maxDelay)
+}
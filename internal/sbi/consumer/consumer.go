@@ -13,13 +13,15 @@ type Consumer struct {
 
 func NewConsumer(eir app.App) *Consumer {
 	configuration := NFManagement.NewConfiguration()
-	configuration.SetBasePath(eir.Context().NrfUri)
+	configuration.SetBasePath(eir.Context().NrfUri())
 	nrfService := &NrfService{
-		nfMngmntClients: make(map[string]*NFManagement.APIClient),
+		nfMngmntClients: make(map[string]*nfMngmntClient),
 	}
 
-	return &Consumer{
+	c := &Consumer{
 		App:        eir,
 		NrfService: nrfService,
 	}
+	nrfService.consumer = c
+	return c
 }
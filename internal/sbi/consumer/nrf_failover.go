@@ -0,0 +1,107 @@
+package consumer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/tracing"
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NRF_UNHEALTHY_DURATION is how long a NRF which couldn't be reached is tried after the others
+const NRF_UNHEALTHY_DURATION = 30 * time.Second
+
+// isNrfUnreachable reports whether the error isn't an answer of the NRF
+func isNrfUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, answered := err.(openapi.GenericOpenAPIError)
+	return !answered
+}
+
+func (ns *NrfService) isNrfHealthy(uri string) bool {
+	ns.nfMngmntMu.RLock()
+	defer ns.nfMngmntMu.RUnlock()
+	client, ok := ns.nfMngmntClients[uri]
+	return !ok || client.failedAt.IsZero() || time.Since(client.failedAt) > NRF_UNHEALTHY_DURATION
+}
+
+// recordNrfHealth records whether the NRF could be reached
+func (ns *NrfService) recordNrfHealth(uri string, err error) {
+	ns.getNFManagementClient(uri)
+
+	ns.nfMngmntMu.Lock()
+	defer ns.nfMngmntMu.Unlock()
+	if isNrfUnreachable(err) {
+		ns.nfMngmntClients[uri].failedAt = time.Now()
+	} else {
+		ns.nfMngmntClients[uri].failedAt = time.Time{}
+	}
+}
+
+// nrfCandidates returns the preferred NRF then the configured ones in order, the unhealthy NRFs are tried last
+func (ns *NrfService) nrfCandidates(preferred string) []string {
+	var uris []string
+	if preferred != "" {
+		uris = append(uris, preferred)
+	}
+	for _, nrf := range eir_context.GetSelf().Nrfs {
+		if nrf.Uri != preferred {
+			uris = append(uris, nrf.Uri)
+		}
+	}
+
+	healthy := make(map[string]bool)
+	for _, uri := range uris {
+		healthy[uri] = ns.isNrfHealthy(uri)
+	}
+	sort.SliceStable(uris, func(i, j int) bool {
+		return healthy[uris[i]] && !healthy[uris[j]]
+	})
+	return uris
+}
+
+// withNrfFailover calls the operation on the NRF candidates until one of them answers, each call is traced as a
+// client span named after the operation. It returns the URI of the NRF which answered
+func (ns *NrfService) withNrfFailover(ctx context.Context, name string, preferred string,
+	operation func(ctx context.Context, uri string) error,
+) (string, error) {
+	var err error
+	for _, uri := range ns.nrfCandidates(preferred) {
+		spanCtx, span := tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("url.full", uri)))
+		err = operation(spanCtx, uri)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		ns.recordNrfHealth(uri, err)
+		if !isNrfUnreachable(err) {
+			return uri, err
+		}
+		logger.ConsumerLog.Warnf("The NRF [%s] can't be reached: %+v", uri, err)
+	}
+	return "", err
+}
+
+// withNrfToken requests the access token to each NRF candidate, so the token of a NRF isn't sent to another
+func withNrfToken(serviceName models.ServiceName, operation func(ctx context.Context, uri string) error,
+) func(ctx context.Context, uri string) error {
+	return func(spanCtx context.Context, uri string) error {
+		ctx, pd, err := eir_context.GetSelf().GetTokenCtxFromNrf(uri, serviceName, models.NrfNfManagementNfType_NRF)
+		if err != nil {
+			logger.ConsumerLog.Errorf("Get token context failed: problem details: %+v", pd)
+			return err
+		}
+		return operation(trace.ContextWithSpan(ctx, trace.SpanFromContext(spanCtx)), uri)
+	}
+}
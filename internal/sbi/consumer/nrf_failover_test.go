@@ -0,0 +1,49 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNrfFailover(t *testing.T) {
+	eirSelf := eir_context.GetSelf()
+	nrfs := eirSelf.Nrfs
+	eirSelf.Nrfs = []factory.Nrf{{Uri: "http://nrf1"}, {Uri: "http://nrf2"}, {Uri: "http://nrf3"}}
+	t.Cleanup(func() { eirSelf.Nrfs = nrfs })
+
+	ns := &NrfService{nfMngmntClients: make(map[string]*nfMngmntClient)}
+	assert.Equal(t, []string{"http://nrf2", "http://nrf1", "http://nrf3"}, ns.nrfCandidates("http://nrf2"))
+
+	var called []string
+	uri, err := ns.withNrfFailover(context.Background(), "Test", "http://nrf1", func(_ context.Context, uri string) error {
+		called = append(called, uri)
+		if uri == "http://nrf3" {
+			return nil
+		}
+		return errors.New("connection refused")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "http://nrf3", uri)
+	assert.Equal(t, []string{"http://nrf1", "http://nrf2", "http://nrf3"}, called)
+
+	// The unreachable NRFs are tried last
+	assert.Equal(t, []string{"http://nrf3", "http://nrf1", "http://nrf2"}, ns.nrfCandidates("http://nrf1"))
+
+	// They are tried in order again once they are considered healthy
+	for _, client := range ns.nfMngmntClients {
+		client.failedAt = client.failedAt.Add(-NRF_UNHEALTHY_DURATION - time.Second)
+	}
+	assert.Equal(t, []string{"http://nrf1", "http://nrf2", "http://nrf3"}, ns.nrfCandidates("http://nrf1"))
+
+	uri, err = ns.withNrfFailover(context.Background(), "Test", "http://nrf1", func(context.Context, string) error {
+		return errors.New("connection refused")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "", uri)
+}
@@ -8,6 +8,8 @@ import (
 
 	eir_context "github.com/adjivas/eir/internal/context"
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/openapi/nrf/NFDiscovery"
 	"github.com/free5gc/openapi/nrf/NFManagement"
@@ -15,13 +17,24 @@ import (
 )
 
 const (
-	DELAY_REGISTRATION_NRF = 2 * time.Second
+	// DEFAULT_HEARTBEAT_TIMER is used when the NRF doesn't return a heartbeat timer
+	DEFAULT_HEARTBEAT_TIMER = 60 * time.Second
 )
 
 type NrfService struct {
+	consumer *Consumer
+
 	nfMngmntMu sync.RWMutex
 
-	nfMngmntClients map[string]*NFManagement.APIClient
+	nfMngmntClients map[string]*nfMngmntClient
+}
+
+// nfMngmntClient is the client of a NRF with its health
+type nfMngmntClient struct {
+	*NFManagement.APIClient
+
+	// failedAt is the time of the last failure to reach the NRF, it's zero when the NRF is healthy
+	failedAt time.Time
 }
 
 func (ns *NrfService) getNFManagementClient(uri string) *NFManagement.APIClient {
@@ -30,27 +43,34 @@ func (ns *NrfService) getNFManagementClient(uri string) *NFManagement.APIClient
 	}
 	ns.nfMngmntMu.RLock()
 	client, ok := ns.nfMngmntClients[uri]
+	ns.nfMngmntMu.RUnlock()
 	if ok {
-		ns.nfMngmntMu.RUnlock()
-		return client
+		return client.APIClient
 	}
 
-	configuration := NFManagement.NewConfiguration()
-	configuration.SetBasePath(uri)
-	client = NFManagement.NewAPIClient(configuration)
-
-	ns.nfMngmntMu.RUnlock()
 	ns.nfMngmntMu.Lock()
 	defer ns.nfMngmntMu.Unlock()
+	if client, ok = ns.nfMngmntClients[uri]; ok {
+		return client.APIClient
+	}
+	configuration := NFManagement.NewConfiguration()
+	configuration.SetBasePath(uri)
+	client = &nfMngmntClient{APIClient: NFManagement.NewAPIClient(configuration)}
 	ns.nfMngmntClients[uri] = client
-	return client
+	return client.APIClient
 }
 
 func (ns *NrfService) buildNFProfile(context *eir_context.EIRContext) (models.NrfNfManagementNfProfile, error) {
 	profile := models.NrfNfManagementNfProfile{
-		NfInstanceId: context.NfId,
+		NfInstanceId: context.NfId(),
 		NfType:       models.NrfNfManagementNfType__5_G_EIR,
 		NfStatus:     models.NrfNfManagementNfStatus_REGISTERED,
+		PlmnList:     context.PlmnList,
+		SNssais:      context.SNssais,
+		Locality:     context.Locality,
+		Capacity:     context.Capacity,
+		Priority:     context.Priority,
+		Fqdn:         context.Fqdn,
 	}
 	if context.RegisterIP.Is6() {
 		profile.Ipv6Addresses = []string{context.RegisterIP.String()}
@@ -79,100 +99,208 @@ func (ns *NrfService) SendRegisterNFInstance(ctx context.Context, nrfUri string)
 		return "", "", err
 	}
 
-	configuration := NFManagement.NewConfiguration()
-	configuration.SetBasePath(nrfUri)
-	client := ns.getNFManagementClient(nrfUri)
-
 	registerNfInstanceRequest := &NFManagement.RegisterNFInstanceRequest{
 		NfInstanceID:             &profile.NfInstanceId,
 		NrfNfManagementNfProfile: &profile,
 	}
+	backoff := newRegistrationBackoff(ns.consumer.Config().Configuration.NrfRegistration)
 	var res *NFManagement.RegisterNFInstanceResponse
 	for {
+		nrfUri, err = ns.withNrfFailover(ctx, "RegisterNFInstance", nrfUri,
+			func(ctx context.Context, uri string) error {
+				client := ns.getNFManagementClient(uri)
+				var registerErr error
+				res, registerErr = client.NFInstanceIDDocumentApi.RegisterNFInstance(ctx, registerNfInstanceRequest)
+				if registerErr == nil && res == nil {
+					return errors.New("empty response")
+				}
+				return registerErr
+			})
+		if err == nil {
+			break
+		}
+		delay := backoff.next()
+		logger.ConsumerLog.Errorf("EIR register to NRF Error[%s], retry in %s", err.Error(), delay)
+
 		select {
 		case <-ctx.Done():
 			return "", "", errors.Errorf("Context Cancel before RegisterNFInstance")
-		default:
-		}
-
-		res, err = client.NFInstanceIDDocumentApi.RegisterNFInstance(ctx, registerNfInstanceRequest)
-		if err != nil || res == nil {
-			logger.ConsumerLog.Errorf("EIR register to NRF Error[%s]", err.Error())
-			time.Sleep(DELAY_REGISTRATION_NRF)
-			continue
+		case <-time.After(delay):
 		}
+	}
 
-		if res.Location == "" {
-			// NFUpdate
-			break
-		}
+	if res.Location == "" {
+		// NFUpdate, the NF instance was already registered
+		resourceNrfUri = nrfUri
+		retrieveNfInstanceId = profile.NfInstanceId
+	} else {
 		resourceUri := res.Location
 		resourceNrfUri, _, _ = strings.Cut(resourceUri, "/nnrf-nfm/")
 		retrieveNfInstanceId = resourceUri[strings.LastIndex(resourceUri, "/")+1:]
+	}
 
-		oauth2 := false
+	oauth2 := false
 
-		if res.NrfNfManagementNfProfile.CustomInfo != nil {
-			if v, ok := res.NrfNfManagementNfProfile.CustomInfo["oauth2"].(bool); ok == true {
-				oauth2 = v
-				logger.MainLog.Infoln("OAuth2 setting receive from NRF:", oauth2)
-			}
+	if res.NrfNfManagementNfProfile.CustomInfo != nil {
+		if v, ok := res.NrfNfManagementNfProfile.CustomInfo["oauth2"].(bool); ok == true {
+			oauth2 = v
+			logger.MainLog.Infoln("OAuth2 setting receive from NRF:", oauth2)
 		}
+	}
 
-		eir_context.GetSelf().OAuth2Required = oauth2
-		if oauth2 && eir_context.GetSelf().NrfCertPem == "" {
-			logger.CfgLog.Error("OAuth2 enable but no nrfCertPem provided in config.")
-		}
-		break
+	eir_context.GetSelf().UseNrf(nrfUri)
+	eir_context.GetSelf().SetOAuth2Required(oauth2)
+	eir_context.GetSelf().SetHeartBeatTimer(DEFAULT_HEARTBEAT_TIMER)
+	if heartBeatTimer := res.NrfNfManagementNfProfile.HeartBeatTimer; heartBeatTimer > 0 {
+		eir_context.GetSelf().SetHeartBeatTimer(time.Duration(heartBeatTimer) * time.Second)
+	}
+	if oauth2 && eir_context.GetSelf().NrfCertPem() == "" {
+		logger.CfgLog.Error("OAuth2 enable but no nrfCertPem provided in config.")
 	}
-	return resourceNrfUri, retrieveNfInstanceId, err
+	return resourceNrfUri, retrieveNfInstanceId, nil
 }
 
 func (ns *NrfService) SendDeregisterNFInstance() (err error) {
 	logger.ConsumerLog.Infof("Send Deregister NFInstance")
 
-	ctx, pd, err := eir_context.GetSelf().GetTokenCtx(models.ServiceName_N5G_EIR_EIC, models.NrfNfManagementNfType_NRF)
-	if err != nil {
-		logger.ConsumerLog.Errorf("Get token context failed: problem details: %+v", pd)
-		return err
-	}
-
 	eirSelf := eir_context.GetSelf()
 
-	// Set client and set url
-	configuration := NFManagement.NewConfiguration()
-	configuration.SetBasePath(eirSelf.NrfUri)
-	client := ns.getNFManagementClient(eirSelf.NrfUri)
-
-	if eirSelf.NfId == "" {
+	nfId := eirSelf.NfId()
+	if nfId == "" {
 		logger.ConsumerLog.Warnf("The EIR haven't a NFId : %+v", eirSelf)
 		return nil
-	} else {
-		deregisterNfInstanceRequest := &NFManagement.DeregisterNFInstanceRequest{
-			NfInstanceID: &eirSelf.NfId,
-		}
-		_, deregisterErr := client.NFInstanceIDDocumentApi.DeregisterNFInstance(ctx, deregisterNfInstanceRequest)
-		if deregisterErr != nil {
+	}
+
+	deregisterNfInstanceRequest := &NFManagement.DeregisterNFInstanceRequest{
+		NfInstanceID: &nfId,
+	}
+	_, err = ns.withNrfFailover(context.TODO(), "DeregisterNFInstance", eirSelf.NrfUri(),
+		withNrfToken(models.ServiceName_N5G_EIR_EIC, func(ctx context.Context, uri string) error {
+			client := ns.getNFManagementClient(uri)
+			_, deregisterErr := client.NFInstanceIDDocumentApi.DeregisterNFInstance(ctx, deregisterNfInstanceRequest)
 			return deregisterErr
+		}))
+	return err
+}
+
+// SendUpdateNFInstance sends the heartbeat of the NF instance, the refusal of the NRF is returned as problem details
+func (ns *NrfService) SendUpdateNFInstance() (*models.NrfNfManagementNfProfile, *models.ProblemDetails, error) {
+	logger.ConsumerLog.Debugf("Send Update NFInstance")
+
+	eirSelf := eir_context.GetSelf()
+
+	nfId := eirSelf.NfId()
+	updateNfInstanceRequest := &NFManagement.UpdateNFInstanceRequest{
+		NfInstanceID: &nfId,
+		PatchItem: []models.PatchItem{
+			{
+				Op:    models.PatchOperation_REPLACE,
+				Path:  "/nfStatus",
+				Value: models.NrfNfManagementNfStatus_REGISTERED,
+			},
+		},
+	}
+	var res *NFManagement.UpdateNFInstanceResponse
+	currentNrfUri := eirSelf.NrfUri()
+	nrfUri, err := ns.withNrfFailover(context.TODO(), "UpdateNFInstance", currentNrfUri,
+		withNrfToken(models.ServiceName_NNRF_NFM, func(ctx context.Context, uri string) error {
+			client := ns.getNFManagementClient(uri)
+			var updateErr error
+			res, updateErr = client.NFInstanceIDDocumentApi.UpdateNFInstance(ctx, updateNfInstanceRequest)
+			return updateErr
+		}))
+	if nrfUri != "" && nrfUri != currentNrfUri {
+		logger.ConsumerLog.Infof("The NRF [%s] is used instead of [%s]", nrfUri, currentNrfUri)
+		eirSelf.UseNrf(nrfUri)
+	}
+	if err != nil {
+		if apiErr, ok := err.(openapi.GenericOpenAPIError); ok {
+			if updateErr, ok := apiErr.Model().(NFManagement.UpdateNFInstanceError); ok {
+				return nil, &updateErr.ProblemDetails, err
+			}
 		}
-		return nil
+		return nil, nil, err
 	}
+	if res == nil {
+		// The NRF answered without the profile
+		return nil, nil, nil
+	}
+	return &res.NrfNfManagementNfProfile, nil, nil
 }
 
 func (ns *NrfService) SendSearchNFInstances(nrfUri string,
 	param NFDiscovery.SearchNFInstancesRequest,
 ) (*NFDiscovery.SearchNFInstancesResponse, error) {
-	// Set client and set url
-	configuration := NFDiscovery.NewConfiguration()
-	configuration.SetBasePath(nrfUri)
-	client := NFDiscovery.NewAPIClient(configuration)
+	var result *NFDiscovery.SearchNFInstancesResponse
+	_, err := ns.withNrfFailover(context.TODO(), "SearchNFInstances", nrfUri,
+		withNrfToken(models.ServiceName_NNRF_DISC, func(ctx context.Context, uri string) error {
+			// Set client and set url
+			configuration := NFDiscovery.NewConfiguration()
+			configuration.SetBasePath(uri)
+			client := NFDiscovery.NewAPIClient(configuration)
 
-	ctx, _, err := eir_context.GetSelf().GetTokenCtx(models.ServiceName_NNRF_DISC, models.NrfNfManagementNfType_NRF)
+			var searchErr error
+			result, searchErr = client.NFInstancesStoreApi.SearchNFInstances(ctx, &param)
+			return searchErr
+		}))
+	return result, err
+}
+
+// SendCreateSubscription subscribes to the status of the AMF instances, the NRF notifies the callback of the EIR
+// with the notification ID. It returns the subscription ID and its validity time, which is zero without one
+func (ns *NrfService) SendCreateSubscription(nrfUri string, notificationId string) (string, time.Time, error) {
+	eirSelf := eir_context.GetSelf()
+	notificationUri := eir_context.GetIPUri() + factory.EirCallbackResUriPrefix + "/nf-status-notify/" + notificationId
+	createSubscriptionRequest := &NFManagement.CreateSubscriptionRequest{
+		NrfNfManagementSubscriptionData: &models.NrfNfManagementSubscriptionData{
+			NfStatusNotificationUri: notificationUri,
+			ReqNfInstanceId:         eirSelf.NfId(),
+			ReqNfType:               models.NrfNfManagementNfType__5_G_EIR,
+			SubscrCond:              models.NfTypeCond{NfType: models.NrfNfManagementNfType_AMF},
+			ReqNotifEvents: []models.NrfNfManagementNotificationEventType{
+				models.NrfNfManagementNotificationEventType_REGISTERED,
+				models.NrfNfManagementNotificationEventType_DEREGISTERED,
+				models.NrfNfManagementNotificationEventType_PROFILE_CHANGED,
+			},
+		},
+	}
+	var res *NFManagement.CreateSubscriptionResponse
+	_, err := ns.withNrfFailover(context.TODO(), "CreateSubscription", nrfUri,
+		withNrfToken(models.ServiceName_NNRF_NFM, func(ctx context.Context, uri string) error {
+			client := ns.getNFManagementClient(uri)
+			var subscriptionErr error
+			res, subscriptionErr = client.SubscriptionsCollectionApi.CreateSubscription(ctx, createSubscriptionRequest)
+			if subscriptionErr == nil && res == nil {
+				return errors.New("empty response")
+			}
+			return subscriptionErr
+		}))
 	if err != nil {
-		return nil, err
+		return "", time.Time{}, err
 	}
 
-	result, err := client.NFInstancesStoreApi.SearchNFInstances(ctx, &param)
+	subscriptionId := res.NrfNfManagementSubscriptionData.SubscriptionId
+	if subscriptionId == "" {
+		// The subscription ID is the last segment of the location
+		subscriptionId = res.Location[strings.LastIndex(res.Location, "/")+1:]
+	}
+	var validityTime time.Time
+	if res.NrfNfManagementSubscriptionData.ValidityTime != nil {
+		validityTime = *res.NrfNfManagementSubscriptionData.ValidityTime
+	}
+	return subscriptionId, validityTime, nil
+}
 
-	return result, err
+// SendRemoveSubscription removes the subscription to the status of the AMF instances
+func (ns *NrfService) SendRemoveSubscription(subscriptionId string) error {
+	removeSubscriptionRequest := &NFManagement.RemoveSubscriptionRequest{
+		SubscriptionID: &subscriptionId,
+	}
+	_, err := ns.withNrfFailover(context.TODO(), "RemoveSubscription", eir_context.GetSelf().NrfUri(),
+		withNrfToken(models.ServiceName_NNRF_NFM, func(ctx context.Context, uri string) error {
+			client := ns.getNFManagementClient(uri)
+			_, removeErr := client.SubscriptionIDDocumentApi.RemoveSubscription(ctx, removeSubscriptionRequest)
+			return removeErr
+		}))
+	return err
 }
@@ -0,0 +1,41 @@
+package consumer
+
+import (
+	"net/netip"
+	"testing"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/free5gc/openapi/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNFProfile(t *testing.T) {
+	ns := &NrfService{}
+	context := &eir_context.EIRContext{
+		RegisterIP: netip.MustParseAddr("127.0.0.54"),
+		PlmnList:   []models.PlmnId{{Mcc: "208", Mnc: "93"}},
+		SNssais:    []models.ExtSnssai{{Sst: 1, Sd: "010203"}},
+		Locality:   "paris",
+		Capacity:   100,
+		Priority:   10,
+		Fqdn:       "eir.5gc.mnc093.mcc208.3gppnetwork.org",
+		NfService: map[models.ServiceName]models.NrfNfManagementNfService{
+			models.ServiceName_N5G_EIR_EIC: {ServiceName: models.ServiceName_N5G_EIR_EIC},
+		},
+	}
+	context.SetNfId("5c0d1a2e-7f00-4b8e-9b1c-000000000001")
+
+	profile, err := ns.buildNFProfile(context)
+	require.Nil(t, err)
+
+	assert.Equal(t, models.NrfNfManagementNfType__5_G_EIR, profile.NfType)
+	assert.Equal(t, []string{"127.0.0.54"}, profile.Ipv4Addresses)
+	assert.Equal(t, context.PlmnList, profile.PlmnList)
+	assert.Equal(t, context.SNssais, profile.SNssais)
+	assert.Equal(t, "paris", profile.Locality)
+	assert.Equal(t, int32(100), profile.Capacity)
+	assert.Equal(t, int32(10), profile.Priority)
+	assert.Equal(t, context.Fqdn, profile.Fqdn)
+	assert.Len(t, profile.NfServices, 1)
+}
@@ -0,0 +1,21 @@
+package sbi
+
+import (
+	"strconv"
+
+	"github.com/adjivas/eir/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// httpMetrics counts the responses by route and status code, the requests without route are counted as unmatched
+func httpMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HttpResponses.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
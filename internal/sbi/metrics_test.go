@@ -0,0 +1,40 @@
+package sbi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/adjivas/eir/internal/metrics"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEIR_EquipmentStatus_Metrics(t *testing.T) {
+	s := setupServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-012345678901237
+    equipment_status: BLACKLISTED
+`, factory.Configuration{
+		DefaultStatus: factory.EquipmentStatusWhitelisted,
+	})
+
+	blacklisted := testutil.ToFloat64(metrics.EquipmentStatusQueries.WithLabelValues("BLACKLISTED"))
+	defaulted := testutil.ToFloat64(metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultDefault))
+	responses := testutil.ToFloat64(metrics.HttpResponses.WithLabelValues(http.MethodGet,
+		factory.EirDrResUriPrefix+"/equipment-status", "200"))
+
+	code, status := queryEquipmentStatus(t, s.router, "pei=imei-012345678901237")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "BLACKLISTED", status)
+	code, status = queryEquipmentStatus(t, s.router, "pei=imei-490154203237518")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "WHITELISTED", status)
+
+	assert.Equal(t, blacklisted+1, testutil.ToFloat64(metrics.EquipmentStatusQueries.WithLabelValues("BLACKLISTED")))
+	assert.Equal(t, defaulted+1,
+		testutil.ToFloat64(metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultDefault)))
+	assert.Equal(t, responses+2, testutil.ToFloat64(metrics.HttpResponses.WithLabelValues(http.MethodGet,
+		factory.EirDrResUriPrefix+"/equipment-status", "200")))
+	assert.Positive(t, testutil.CollectAndCount(metrics.DatabaseDuration))
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/gin-gonic/gin"
+)
+
+// newAuditor returns the auditor of the configuration, it's nil without audit
+func newAuditor(config *factory.Audit, dbConnector database.DbConnector) (*audit.Auditor, error) {
+	if config == nil {
+		return nil, nil
+	}
+	sink, err := audit.NewSink(config, dbConnector)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewAuditor(sink), nil
+}
+
+// consumerNfId returns the NF instance ID of the urn:uuid URI SAN of the client certificate,
+// else the source NF instance of the 3gpp-Sbi-NF-Peer-Info header
+func consumerNfId(c *gin.Context) string {
+	if tls := c.Request.TLS; tls != nil && len(tls.PeerCertificates) > 0 {
+		for _, uri := range tls.PeerCertificates[0].URIs {
+			if nfInstanceId, found := strings.CutPrefix(uri.String(), "urn:uuid:"); found {
+				return nfInstanceId
+			}
+		}
+	}
+	for _, param := range strings.Split(c.GetHeader("3gpp-Sbi-NF-Peer-Info"), ";") {
+		if nfInstanceId, found := strings.CutPrefix(strings.TrimSpace(param), "srcinst="); found {
+			return nfInstanceId
+		}
+	}
+	return ""
+}
+
+// recordAudit writes the audit record of the request, a failure is only logged to keep serving
+func (p *Processor) recordAudit(c *gin.Context, record audit.Record) {
+	record.ConsumerNfId = consumerNfId(c)
+	if err := p.audit.Record(record); err != nil {
+		logger.ProcLog.Errorf("The audit record of [%s] can't be written: %+v", record.Event, err)
+	}
+}
+
+func tacRuleDescription(tac string, snrStart string, snrEnd string) string {
+	if snrStart == "" {
+		return fmt.Sprintf("tac-rule %s", tac)
+	}
+	return fmt.Sprintf("tac-rule %s [%s-%s]", tac, snrStart, snrEnd)
+}
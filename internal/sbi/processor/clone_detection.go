@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ClonedPei is a PEI which was paired with too many SUPIs during the clone detection window
+type ClonedPei struct {
+	Pei        string   `json:"pei"`
+	Supis      []string `json:"supis"`
+	DetectedAt string   `json:"detected_at"` // RFC 3339 time of the last detection
+}
+
+// observePairing records the pairing of the PEI with the SUPI, it reports whether the PEI is flagged as cloned.
+// A flagged PEI stays flagged until its flag is deleted.
+func (p *Processor) observePairing(c *gin.Context, observationCollName string, clonedCollName string,
+	pei string, supi string, now time.Time,
+) bool {
+	cloneDetection := p.App.Config().Configuration.CloneDetection
+	if cloneDetection == nil {
+		return false
+	}
+
+	var supis []string
+	if supi != "" {
+		supis = p.recentSupis(observationCollName, pei, supi, now, cloneDetection.Window)
+	}
+	if len(supis) > cloneDetection.MaxSupis {
+		logger.ProcLog.Warnf("The PEI [%s] is cloned, it's paired with %d SUPIs in %s: %v",
+			pei, len(supis), cloneDetection.Window, supis)
+		cloned := map[string]interface{}{
+			"pei":         pei,
+			"supis":       supis,
+			"detected_at": now.UTC().Format(time.RFC3339),
+		}
+		filter := map[string]interface{}{"pei": pei}
+		if _, errDatabase := p.DbConnector.PutDataToDB(clonedCollName, filter, cloned); errDatabase != nil {
+			logger.ProcLog.Errorf("The cloned [%s] can't be stored: %s", pei, errDatabase.Detail)
+		} else {
+			p.recordAudit(c, audit.Record{
+				Event:       audit.EventDetectClonedPei,
+				Pei:         pei,
+				Supi:        supi,
+				Result:      audit.ResultCloned,
+				MatchedRule: fmt.Sprintf("clone-detection %d SUPIs in %s", len(supis), cloneDetection.Window),
+			})
+		}
+		return true
+	}
+
+	_, errDatabase := p.DbConnector.GetDataFromDB(clonedCollName, map[string]interface{}{"pei": pei})
+	if errDatabase != nil && errDatabase.Cause != "DATA_NOT_FOUND" {
+		logger.ProcLog.Errorf("The clone flag of [%s] can't be looked up: %s", pei, errDatabase.Detail)
+	}
+	return errDatabase == nil
+}
+
+// recentSupis stores the observation of the pairing and returns the SUPIs paired with the PEI during the window,
+// the older observations are deleted
+func (p *Processor) recentSupis(collName string, pei string, supi string, now time.Time,
+	window time.Duration,
+) []string {
+	observation := map[string]interface{}{
+		"pei":         pei,
+		"supi":        supi,
+		"observed_at": now.UTC().Format(time.RFC3339),
+	}
+	if _, errDatabase := p.DbConnector.PutDataToDB(collName, map[string]interface{}{"pei": pei, "supi": supi},
+		observation); errDatabase != nil {
+		logger.ProcLog.Errorf("The pairing of [%s] and [%s] can't be stored: %s", pei, supi, errDatabase.Detail)
+		return nil
+	}
+
+	observations, errDatabase := p.DbConnector.GetManyDataFromDB(collName, map[string]interface{}{"pei": pei})
+	if errDatabase != nil {
+		logger.ProcLog.Errorf("The pairings of [%s] can't be looked up: %s", pei, errDatabase.Detail)
+		return nil
+	}
+
+	var supis []string
+	for _, observation := range observations {
+		observedSupi := recordField(observation, "supi")
+		observedAt, err := time.Parse(time.RFC3339, recordField(observation, "observed_at"))
+		// The observation just stored is counted whatever the precision of its time
+		if observedSupi == supi || (err == nil && now.Sub(observedAt) <= window) {
+			supis = append(supis, observedSupi)
+			continue
+		}
+		filter := map[string]interface{}{"pei": pei, "supi": observedSupi}
+		if errDatabase := p.DbConnector.DeleteDataFromDB(collName, filter); errDatabase != nil {
+			logger.ProcLog.Warnf("The old pairing of [%s] and [%s] can't be deleted: %s",
+				pei, observedSupi, errDatabase.Detail)
+		}
+	}
+	sort.Strings(supis)
+	return supis
+}
+
+func (p *Processor) ListClonedPeisProcedure(c *gin.Context, collName string) {
+	documents, errDatabase := p.DbConnector.GetManyDataFromDB(collName, map[string]interface{}{})
+	if errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	clonedPeis := []ClonedPei{}
+	for _, data := range documents {
+		var cloned ClonedPei
+		content, err := json.Marshal(data)
+		if err == nil {
+			err = json.Unmarshal(content, &cloned)
+		}
+		if err != nil {
+			provisioningDatabaseFailure(c, &models.ProblemDetails{Detail: err.Error()})
+			return
+		}
+		clonedPeis = append(clonedPeis, cloned)
+	}
+	c.JSON(http.StatusOK, clonedPeis)
+}
+
+// DeleteClonedPeiProcedure clears the flag and the pairings of a PEI
+func (p *Processor) DeleteClonedPeiProcedure(c *gin.Context, collName string, observationCollName string,
+	pei string,
+) {
+	filter := map[string]interface{}{"pei": pei}
+	if _, errDatabase := p.DbConnector.GetDataFromDB(collName, filter); errDatabase != nil {
+		if errDatabase.Cause == "DATA_NOT_FOUND" {
+			logger.ProcLog.Errorln("The cloned PEI wasn't found")
+			problemDetail := models.ProblemDetails{
+				Title:  provisioningFailedTitle,
+				Status: http.StatusNotFound,
+				Detail: "The cloned PEI wasn't found",
+				Cause:  "DATA_NOT_FOUND",
+			}
+			c.JSON(http.StatusNotFound, problemDetail)
+		} else {
+			provisioningDatabaseFailure(c, errDatabase)
+		}
+		return
+	}
+
+	if errDatabase := p.DbConnector.DeleteManyDataFromDB(observationCollName, filter); errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+	if errDatabase := p.DbConnector.DeleteDataFromDB(collName, filter); errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The clone flag of [%s] is deleted", pei)
+	p.recordAudit(c, audit.Record{Event: audit.EventDeleteClonedPei, Pei: pei, Result: audit.ResultDeleted})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,235 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	greylistNotificationTimeout = 5 * time.Second
+	// greylistNotificationQueue bounds the notifications waiting for a worker, the next ones are dropped
+	greylistNotificationQueue   = 256
+	greylistNotificationWorkers = 4
+	// greylistCounterExpiry forgets the lookups of a record which isn't looked up anymore
+	greylistCounterExpiry = 24 * time.Hour
+)
+
+// GreylistNotification is posted to the notificationUri of the notify policies
+type GreylistNotification struct {
+	Pei       string    `json:"pei"`
+	Supi      string    `json:"supi,omitempty"`
+	Gpsi      string    `json:"gpsi,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Lookups   int       `json:"lookups"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type greylistLookups struct {
+	count int
+	last  time.Time
+}
+
+// greylistCounter counts the lookups of each grey-listed record since it was grey-listed,
+// the records not looked up during greylistCounterExpiry are forgotten
+type greylistCounter struct {
+	mu        sync.Mutex
+	lookups   map[string]*greylistLookups
+	lastSweep time.Time
+}
+
+func newGreylistCounter() *greylistCounter {
+	return &greylistCounter{
+		lookups:   make(map[string]*greylistLookups),
+		lastSweep: time.Now(),
+	}
+}
+
+func (g *greylistCounter) increment(key string, now time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.lastSweep) >= greylistCounterExpiry {
+		for lookupsKey, lookups := range g.lookups {
+			if now.Sub(lookups.last) >= greylistCounterExpiry {
+				delete(g.lookups, lookupsKey)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	lookups, ok := g.lookups[key]
+	if !ok || now.Sub(lookups.last) >= greylistCounterExpiry {
+		lookups = &greylistLookups{}
+		g.lookups[key] = lookups
+	}
+	lookups.count++
+	lookups.last = now
+	return lookups.count
+}
+
+func (g *greylistCounter) reset(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.lookups, key)
+}
+
+// greylistPolicy returns the first policy matching the reason of the record
+func greylistPolicy(policies []*factory.GreylistPolicy, reason string) *factory.GreylistPolicy {
+	for _, policy := range policies {
+		if policy.Reason == "" || policy.Reason == reason {
+			return policy
+		}
+	}
+	return nil
+}
+
+func recordField(data map[string]interface{}, field string) string {
+	value, _ := data[field].(string)
+	return value
+}
+
+// applyGreylistPolicy decides the status returned for a grey-listed record. Only a stored record is escalated
+// in the database, the status derived from a TAC rule or a default stays BLACKLISTED as long as its lookups are counted.
+func (p *Processor) applyGreylistPolicy(c *gin.Context, collName string, data map[string]interface{},
+	stored bool,
+) string {
+	pei, supi, gpsi := recordField(data, "pei"), recordField(data, "supi"), recordField(data, "gpsi")
+	reason := recordField(data, "reason")
+	key := fmt.Sprintf("%s/%s/%s", pei, supi, gpsi)
+	lookups := p.greylist.increment(key, time.Now())
+
+	policy := greylistPolicy(p.App.Config().Configuration.GreylistPolicies, reason)
+	if policy == nil {
+		logger.ProcLog.Warnf("The grey-listed [%s] is looked up (%d times)", pei, lookups)
+		return factory.EquipmentStatusGreylisted
+	}
+
+	switch policy.Action {
+	case factory.GreylistActionNotify:
+		logger.ProcLog.Warnf("The grey-listed [%s] is looked up (%d times), notifying %s",
+			pei, lookups, policy.NotificationUri)
+		p.greylistNotifier.notify(policy.NotificationUri, GreylistNotification{
+			Pei:       pei,
+			Supi:      supi,
+			Gpsi:      gpsi,
+			Reason:    reason,
+			Lookups:   lookups,
+			Timestamp: time.Now(),
+		})
+	case factory.GreylistActionEscalate:
+		if lookups < policy.EscalateAfter {
+			logger.ProcLog.Warnf("The grey-listed [%s] is looked up (%d/%d times)", pei, lookups, policy.EscalateAfter)
+			break
+		}
+		logger.ProcLog.Warnf("The grey-listed [%s] is escalated to %s after %d lookups",
+			pei, factory.EquipmentStatusBlacklisted, lookups)
+		if !stored {
+			return factory.EquipmentStatusBlacklisted
+		}
+		escalation := map[string]interface{}{
+			"equipment_status": factory.EquipmentStatusBlacklisted,
+		}
+		filter := provisionedEquipmentFilter(pei, supi, gpsi)
+		if _, errDatabase := p.DbConnector.PutDataToDB(collName, filter, escalation); errDatabase != nil {
+			logger.ProcLog.Errorf("The escalation of [%s] can't be stored: %s", pei, errDatabase.Detail)
+		} else {
+			p.greylist.reset(key)
+			p.recordAudit(c, audit.Record{
+				Event:       audit.EventEscalateGreylisted,
+				Pei:         pei,
+				Supi:        supi,
+				Gpsi:        gpsi,
+				Result:      factory.EquipmentStatusBlacklisted,
+				MatchedRule: fmt.Sprintf("greylist-policy after %d lookups", lookups),
+			})
+		}
+		return factory.EquipmentStatusBlacklisted
+	default:
+		logger.ProcLog.Warnf("The grey-listed [%s] is looked up (%d times)", pei, lookups)
+	}
+	return factory.EquipmentStatusGreylisted
+}
+
+type greylistNotificationJob struct {
+	uri          string
+	notification GreylistNotification
+}
+
+// greylistNotifier posts the notifications from a bounded queue, so the lookups never wait for the receivers
+type greylistNotifier struct {
+	client *http.Client
+	queue  chan greylistNotificationJob
+	start  sync.Once
+}
+
+func newGreylistNotifier() *greylistNotifier {
+	return &greylistNotifier{
+		client: &http.Client{Timeout: greylistNotificationTimeout},
+		queue:  make(chan greylistNotificationJob, greylistNotificationQueue),
+	}
+}
+
+// notify queues the notification, it's dropped when the queue is full
+func (n *greylistNotifier) notify(uri string, notification GreylistNotification) {
+	n.start.Do(func() {
+		for i := 0; i < greylistNotificationWorkers; i++ {
+			go n.work()
+		}
+	})
+
+	select {
+	case n.queue <- greylistNotificationJob{uri: uri, notification: notification}:
+	default:
+		logger.ProcLog.Errorf("The grey-list notification queue is full, the notification of [%s] to %s is dropped",
+			notification.Pei, uri)
+	}
+}
+
+func (n *greylistNotifier) work() {
+	for job := range n.queue {
+		n.post(job.uri, job.notification)
+	}
+}
+
+func (n *greylistNotifier) post(uri string, notification GreylistNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logger.ProcLog.Errorf("The grey-list notification can't be encoded: %+v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), greylistNotificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(body))
+	if err != nil {
+		logger.ProcLog.Errorf("The grey-list notification can't be created: %+v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := n.client.Do(req)
+	if err != nil {
+		logger.ProcLog.Errorf("The grey-list notification to %s has failed: %+v", uri, err)
+		return
+	}
+	defer func() {
+		if closeErr := rsp.Body.Close(); closeErr != nil {
+			logger.ProcLog.Warnf("The grey-list notification body can't be closed: %+v", closeErr)
+		}
+	}()
+	if rsp.StatusCode >= http.StatusBadRequest {
+		logger.ProcLog.Errorf("The grey-list notification to %s is refused with %d", uri, rsp.StatusCode)
+	}
+}
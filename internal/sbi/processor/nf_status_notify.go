@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"net/http"
+	"strings"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+const nfStatusNotifyFailedTitle = "The NF status notification has failed"
+
+// NfStatusNotifyProcedure updates the AMF peers with the NF status notified by the NRF
+func (p *Processor) NfStatusNotifyProcedure(c *gin.Context, notification models.NrfNfManagementNotificationData) {
+	// The NF instance ID is the last segment of the NF instance URI
+	nfInstanceId := notification.NfInstanceUri[strings.LastIndex(notification.NfInstanceUri, "/")+1:]
+
+	switch notification.Event {
+	case models.NrfNfManagementNotificationEventType_REGISTERED,
+		models.NrfNfManagementNotificationEventType_PROFILE_CHANGED:
+		profile := notification.NfProfile
+		if profile == nil {
+			problemDetail := models.ProblemDetails{
+				Title:  nfStatusNotifyFailedTitle,
+				Status: http.StatusBadRequest,
+				Detail: "The NF profile is missing",
+				Cause:  "MANDATORY_IE_MISSING",
+			}
+			logger.ProcLog.Errorf("The NF profile of [%s] is missing", nfInstanceId)
+			c.JSON(http.StatusBadRequest, problemDetail)
+			return
+		}
+		if profile.NfType != models.NrfNfManagementNfType_AMF {
+			logger.ProcLog.Debugf("The NF [%s] isn't an AMF, it's ignored", nfInstanceId)
+			break
+		}
+		if profile.NfInstanceId == "" {
+			profile.NfInstanceId = nfInstanceId
+		}
+		p.Context().SetAmfPeer(eir_context.AmfPeerFromNfProfile(profile))
+		logger.ProcLog.Infof("The AMF peer [%s] is %s", profile.NfInstanceId, notification.Event)
+	case models.NrfNfManagementNotificationEventType_DEREGISTERED:
+		if p.Context().RemoveAmfPeer(nfInstanceId) {
+			logger.ProcLog.Infof("The AMF peer [%s] is %s", nfInstanceId, notification.Event)
+		}
+	default:
+		problemDetail := models.ProblemDetails{
+			Title:  nfStatusNotifyFailedTitle,
+			Status: http.StatusBadRequest,
+			Detail: "The event [" + string(notification.Event) + "] isn't supported",
+			Cause:  "INVALID_MSG_FORMAT",
+		}
+		logger.ProcLog.Errorf("The NF status event [%s] isn't supported", notification.Event)
+		c.JSON(http.StatusBadRequest, problemDetail)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
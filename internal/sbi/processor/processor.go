@@ -1,18 +1,46 @@
 package processor
 
 import (
+	"context"
+
+	"github.com/adjivas/eir/internal/audit"
 	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/internal/logger"
 	"github.com/adjivas/eir/pkg/app"
 )
 
 type Processor struct {
 	app.App
 	database.DbConnector
+
+	greylist         *greylistCounter
+	greylistNotifier *greylistNotifier
+	// audit is nil when the audit isn't configured
+	audit *audit.Auditor
 }
 
 func NewProcessor(eir app.App) *Processor {
+	dbConnector := database.NewDbConnector(eir.Config().Configuration.DbConnectorType)
+	auditor, err := newAuditor(eir.Config().Configuration.Audit, dbConnector)
+	if err != nil {
+		logger.ProcLog.Fatalf("The audit can't be initialized: %+v", err)
+	}
+	return &Processor{
+		App:              eir,
+		DbConnector:      dbConnector,
+		greylist:         newGreylistCounter(),
+		greylistNotifier: newGreylistNotifier(),
+		audit:            auditor,
+	}
+}
+
+// withContext returns the processor whose database calls are traced as child spans of the context
+func (p *Processor) withContext(ctx context.Context) *Processor {
 	return &Processor{
-		App:         eir,
-		DbConnector: database.NewDbConnector(eir.Config().Configuration.DbConnectorType),
+		App:              p.App,
+		DbConnector:      database.WithContext(ctx, p.DbConnector),
+		greylist:         p.greylist,
+		greylistNotifier: p.greylistNotifier,
+		audit:            p.audit,
 	}
 }
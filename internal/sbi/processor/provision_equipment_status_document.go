@@ -0,0 +1,315 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/util"
+	"github.com/adjivas/eir/pkg/factory"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+const provisioningFailedTitle = "The equipment provisioning has failed"
+
+// EquipmentStatusRecord is the provisioned document, it's stored as it's exchanged
+type EquipmentStatusRecord struct {
+	Pei             string `json:"pei"`
+	Supi            string `json:"supi,omitempty"`
+	Gpsi            string `json:"gpsi,omitempty"`
+	EquipmentStatus string `json:"equipment_status"`
+	Reason          string `json:"reason,omitempty"`
+	ValidFrom       string `json:"valid_from,omitempty"`  // RFC 3339 time from which the record applies
+	ValidUntil      string `json:"valid_until,omitempty"` // RFC 3339 time until which the record applies
+}
+
+// provisionedEquipmentFilter matches exactly one record: a missing SUPI/GPSI must be missing on the record too
+func provisionedEquipmentFilter(pei string, supi string, gpsi string) map[string]interface{} {
+	filter := map[string]interface{}{
+		"pei":  pei,
+		"supi": nil,
+		"gpsi": nil,
+	}
+	if supi != "" {
+		filter["supi"] = supi
+	}
+	if gpsi != "" {
+		filter["gpsi"] = gpsi
+	}
+	return filter
+}
+
+func (r *EquipmentStatusRecord) toMap() map[string]interface{} {
+	data := map[string]interface{}{
+		"pei":              r.Pei,
+		"equipment_status": r.EquipmentStatus,
+	}
+	optionals := map[string]string{
+		"supi":        r.Supi,
+		"gpsi":        r.Gpsi,
+		"reason":      r.Reason,
+		"valid_from":  r.ValidFrom,
+		"valid_until": r.ValidUntil,
+	}
+	for field, value := range optionals {
+		if value != "" {
+			data[field] = value
+		}
+	}
+	return data
+}
+
+// toReplacementMap also clears the optional fields missing from the record, the writes merge the fields
+func (r *EquipmentStatusRecord) toReplacementMap() map[string]interface{} {
+	data := r.toMap()
+	for _, field := range []string{"reason", "valid_from", "valid_until"} {
+		if _, ok := data[field]; !ok {
+			data[field] = nil
+		}
+	}
+	return data
+}
+
+// validate completes the record with the identity of the resource and checks that nothing contradicts it,
+// the invalid parameters are listed in the order of the fields
+func (r *EquipmentStatusRecord) validate(pei string, supi string, gpsi string) []models.InvalidParam {
+	var invalidParams []models.InvalidParam
+
+	// The PEI is parsed as the lookups parse it, a malformed PEI would never be matched
+	if _, err := util.ParsePei(pei); err != nil {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "pei",
+			Reason: err.Error(),
+		})
+	}
+	if r.Pei == "" {
+		r.Pei = pei
+	} else if r.Pei != pei {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "pei",
+			Reason: "The PEI doesn't match the resource",
+		})
+	}
+	if r.Supi == "" {
+		r.Supi = supi
+	} else if r.Supi != supi {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "supi",
+			Reason: "The SUPI doesn't match the resource",
+		})
+	}
+	if r.Gpsi == "" {
+		r.Gpsi = gpsi
+	} else if r.Gpsi != gpsi {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "gpsi",
+			Reason: "The GPSI doesn't match the resource",
+		})
+	}
+	for _, validity := range []struct{ param, value string }{
+		{param: "valid_from", value: r.ValidFrom},
+		{param: "valid_until", value: r.ValidUntil},
+	} {
+		if _, err := time.Parse(time.RFC3339, validity.value); validity.value != "" && err != nil {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  validity.param,
+				Reason: fmt.Sprintf("The %s isn't a RFC 3339 time", validity.param),
+			})
+		}
+	}
+	if !factory.IsValidEquipmentStatus(r.EquipmentStatus) {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "equipment_status",
+			Reason: fmt.Sprintf("The Equipment Status [%s] isn't one of %v", r.EquipmentStatus, factory.EquipmentStatuses),
+		})
+	}
+
+	return invalidParams
+}
+
+func provisioningBadRequest(c *gin.Context, detail string, invalidParams []models.InvalidParam) {
+	logger.ProcLog.Errorf("The provisioning request is invalid: %s %+v", detail, invalidParams)
+	problemDetail := models.ProblemDetails{
+		Title:         provisioningFailedTitle,
+		Status:        http.StatusBadRequest,
+		Detail:        detail,
+		Cause:         "MANDATORY_IE_INCORRECT",
+		InvalidParams: invalidParams,
+	}
+	c.JSON(http.StatusBadRequest, problemDetail)
+}
+
+func provisioningNotFound(c *gin.Context) {
+	logger.ProcLog.Errorln("The Equipment Status wasn't found")
+	problemDetail := models.ProblemDetails{
+		Title:  provisioningFailedTitle,
+		Status: http.StatusNotFound,
+		Detail: "The Equipment Status wasn't found",
+		Cause:  "ERROR_EQUIPMENT_UNKNOWN",
+	}
+	c.JSON(http.StatusNotFound, problemDetail)
+}
+
+func provisioningDatabaseFailure(c *gin.Context, errDatabase *models.ProblemDetails) {
+	logger.ProcLog.Errorf("The database has failed with [%v]", errDatabase.Detail)
+	problemDetail := models.ProblemDetails{
+		Title:  provisioningFailedTitle,
+		Status: http.StatusInternalServerError,
+		Detail: errDatabase.Detail,
+		Cause:  "INSUFFICIENT_RESOURCES",
+	}
+	c.JSON(http.StatusInternalServerError, problemDetail)
+}
+
+func (p *Processor) CreateEquipmentStatusProcedure(c *gin.Context, collName string,
+	pei string, supi string, gpsi string, record EquipmentStatusRecord,
+) {
+	if invalidParams := record.validate(pei, supi, gpsi); len(invalidParams) > 0 {
+		provisioningBadRequest(c, "The Equipment Status record is invalid", invalidParams)
+		return
+	}
+
+	filter := provisionedEquipmentFilter(pei, supi, gpsi)
+	existed, errDatabase := p.DbConnector.PostDataToDB(collName, filter, record.toMap())
+	if errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+	if existed {
+		logger.ProcLog.Errorf("The Equipment Status of [%s] already exists", pei)
+		problemDetail := models.ProblemDetails{
+			Title:  provisioningFailedTitle,
+			Status: http.StatusConflict,
+			Detail: "The Equipment Status already exists",
+			Cause:  "EQUIPMENT_ALREADY_EXISTS",
+		}
+		c.JSON(http.StatusConflict, problemDetail)
+		return
+	}
+
+	logger.ProcLog.Infof("The Equipment Status of [%s] is created as %s", pei, record.EquipmentStatus)
+	p.recordAudit(c, audit.Record{
+		Event: audit.EventCreateEquipmentStatus, Pei: pei, Supi: supi, Gpsi: gpsi, Result: record.EquipmentStatus,
+	})
+	c.Header("Location", c.Request.URL.String())
+	c.JSON(http.StatusCreated, record)
+}
+
+func (p *Processor) ReplaceEquipmentStatusProcedure(c *gin.Context, collName string,
+	pei string, supi string, gpsi string, record EquipmentStatusRecord,
+) {
+	if invalidParams := record.validate(pei, supi, gpsi); len(invalidParams) > 0 {
+		provisioningBadRequest(c, "The Equipment Status record is invalid", invalidParams)
+		return
+	}
+
+	filter := provisionedEquipmentFilter(pei, supi, gpsi)
+	existed, errDatabase := p.DbConnector.PutDataToDB(collName, filter, record.toReplacementMap())
+	if errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The Equipment Status of [%s] is replaced by %s", pei, record.EquipmentStatus)
+	p.recordAudit(c, audit.Record{
+		Event: audit.EventReplaceEquipmentStatus, Pei: pei, Supi: supi, Gpsi: gpsi, Result: record.EquipmentStatus,
+	})
+	if existed {
+		c.JSON(http.StatusOK, record)
+	} else {
+		c.Header("Location", c.Request.URL.String())
+		c.JSON(http.StatusCreated, record)
+	}
+}
+
+func (p *Processor) ModifyEquipmentStatusProcedure(c *gin.Context, collName string,
+	pei string, supi string, gpsi string, patchJSON []byte,
+) {
+	patch, err := jsonpatch.DecodePatch(patchJSON)
+	if err != nil {
+		provisioningBadRequest(c, fmt.Sprintf("The JSON Patch is malformed: %+v", err), nil)
+		return
+	}
+
+	filter := provisionedEquipmentFilter(pei, supi, gpsi)
+	data, errDatabase := p.DbConnector.GetDataFromDB(collName, filter)
+	if errDatabase != nil {
+		if errDatabase.Cause == "DATA_NOT_FOUND" {
+			provisioningNotFound(c)
+		} else {
+			provisioningDatabaseFailure(c, errDatabase)
+		}
+		return
+	}
+
+	var record EquipmentStatusRecord
+	original, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(original, &record)
+	}
+	if err == nil {
+		// Marshal the record again to only patch the provisioned fields
+		original, err = json.Marshal(record)
+	}
+	if err != nil {
+		provisioningDatabaseFailure(c, &models.ProblemDetails{Detail: err.Error()})
+		return
+	}
+
+	modified, err := patch.Apply(original)
+	if err != nil {
+		provisioningBadRequest(c, fmt.Sprintf("The JSON Patch can't be applied: %+v", err), nil)
+		return
+	}
+
+	var patched EquipmentStatusRecord
+	if err = json.Unmarshal(modified, &patched); err != nil {
+		provisioningBadRequest(c, fmt.Sprintf("The patched record is malformed: %+v", err), nil)
+		return
+	}
+	if invalidParams := patched.validate(pei, supi, gpsi); len(invalidParams) > 0 {
+		provisioningBadRequest(c, "The patched Equipment Status record is invalid", invalidParams)
+		return
+	}
+
+	if _, errDatabase = p.DbConnector.PutDataToDB(collName, filter, patched.toReplacementMap()); errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The Equipment Status of [%s] is patched to %s", pei, patched.EquipmentStatus)
+	p.recordAudit(c, audit.Record{
+		Event: audit.EventModifyEquipmentStatus, Pei: pei, Supi: supi, Gpsi: gpsi, Result: patched.EquipmentStatus,
+	})
+	c.JSON(http.StatusOK, patched)
+}
+
+func (p *Processor) DeleteEquipmentStatusProcedure(c *gin.Context, collName string,
+	pei string, supi string, gpsi string,
+) {
+	filter := provisionedEquipmentFilter(pei, supi, gpsi)
+	if _, errDatabase := p.DbConnector.GetDataFromDB(collName, filter); errDatabase != nil {
+		if errDatabase.Cause == "DATA_NOT_FOUND" {
+			provisioningNotFound(c)
+		} else {
+			provisioningDatabaseFailure(c, errDatabase)
+		}
+		return
+	}
+
+	if errDatabase := p.DbConnector.DeleteDataFromDB(collName, filter); errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The Equipment Status of [%s] is deleted", pei)
+	p.recordAudit(c, audit.Record{
+		Event: audit.EventDeleteEquipmentStatus, Pei: pei, Supi: supi, Gpsi: gpsi, Result: audit.ResultDeleted,
+	})
+	c.Status(http.StatusNoContent)
+}
@@ -1,18 +1,24 @@
 package processor
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/adjivas/eir/internal/audit"
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/metrics"
+	"github.com/adjivas/eir/internal/tracing"
 	"github.com/adjivas/eir/internal/util"
+	"github.com/adjivas/eir/pkg/factory"
 	eir_api_service "github.com/free5gc/openapi/eir/EIRService"
 	"github.com/free5gc/openapi/models"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func (p *Processor) GetEirEquipmentStatusProcedure(c *gin.Context, collName string,
-	pei string, supi string, gpsi string,
-) {
+func equipmentStatusFilter(pei string, supi string, gpsi string) map[string]interface{} {
 	filter := map[string]interface{}{
 		"pei": pei,
 	}
@@ -22,24 +28,198 @@ func (p *Processor) GetEirEquipmentStatusProcedure(c *gin.Context, collName stri
 	if gpsi != "" {
 		filter["gpsi"] = gpsi
 	}
+	return filter
+}
+
+// inValidity reports whether the record applies at the time, a record without validity always applies
+func inValidity(data map[string]interface{}, now time.Time) bool {
+	if validFrom, ok := data["valid_from"].(string); ok {
+		if from, err := time.Parse(time.RFC3339, validFrom); err == nil && now.Before(from) {
+			return false
+		}
+	}
+	if validUntil, ok := data["valid_until"].(string); ok {
+		if until, err := time.Parse(time.RFC3339, validUntil); err == nil && now.After(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupKeys lists the PEIs to look up in order according to the strategy
+func lookupKeys(pei string, strategy string) []string {
+	keys := []string{pei}
+	if strategy == factory.PeiLookupExact {
+		return keys
+	}
+	if parsed, err := util.ParsePei(pei); err == nil && parsed.Type == util.PeiTypeImeisv {
+		imei := parsed.Imei()
+		// Some blacklists are keyed by the 14 digits of the TAC and the SNR, without the check digit
+		keys = append(keys, imei, imei[:len(imei)-1])
+	}
+	return keys
+}
+
+// lookupEquipmentStatus returns the first record in validity matching one of the keys
+func (p *Processor) lookupEquipmentStatus(collName string, keys []string, supi string, gpsi string,
+) (map[string]interface{}, *models.ProblemDetails) {
+	for _, key := range keys {
+		data, err_database := p.DbConnector.GetDataFromDB(collName, equipmentStatusFilter(key, supi, gpsi))
+		if err_database != nil {
+			if err_database.Cause == "DATA_NOT_FOUND" {
+				continue
+			}
+			return nil, err_database
+		}
+		if !inValidity(data, time.Now()) {
+			logger.ProcLog.Infof("The Equipment Status of [%s] is out of its validity", key)
+			continue
+		}
+		if key != keys[0] {
+			logger.ProcLog.Infof("The Equipment Status of [%s] is matched by the [%s] key", keys[0], key)
+		} else {
+			logger.ProcLog.Debugf("The Equipment Status of [%s] is matched by the exact key", key)
+		}
+		return data, nil
+	}
+	return nil, &models.ProblemDetails{
+		Status: http.StatusNotFound,
+		Cause:  "DATA_NOT_FOUND",
+	}
+}
+
+// LookupCollections are the collections used by the equipment status lookup
+type LookupCollections struct {
+	EquipmentStatus string
+	TacRules        string
+	TacModels       string
+	PeiObservations string
+	ClonedPeis      string
+}
+
+// GetEirEquipmentStatusProcedure returns the status of the exact PEI record, else of the narrowest TAC rule,
+// else the UnallocatedTacStatus when the TAC isn't allocated, else the DefaultStatus.
+// A WHITELISTED status of a cloned PEI is GREYLISTED when the clone detection grey-lists automatically.
+func (p *Processor) GetEirEquipmentStatusProcedure(c *gin.Context, colls LookupCollections,
+	pei string, supi string, gpsi string,
+) {
+	ctx, span := tracing.Tracer().Start(c.Request.Context(), "GetEirEquipmentStatusProcedure")
+	defer span.End()
+	p = p.withContext(ctx)
+
+	collName := colls.EquipmentStatus
+	configuration := p.App.Config().Configuration
+	keys := lookupKeys(pei, configuration.PeiLookupStrategy)
+
+	model, err_model := p.lookupTacModel(colls.TacModels, pei)
+	if err_model != nil {
+		logger.ProcLog.Warnf("The TAC model of [%s] can't be looked up: %s", pei, err_model.Detail)
+	}
+	autoGreylisted := p.observePairing(c, colls.PeiObservations, colls.ClonedPeis, pei, supi, time.Now()) &&
+		configuration.CloneDetection.AutoGreylist
+
+	// auditCheck records the result of the check and the rule which decided it
+	auditCheck := func(result string, matchedRule string) {
+		record := audit.Record{
+			Event:       audit.EventCheck,
+			Pei:         pei,
+			Supi:        supi,
+			Gpsi:        gpsi,
+			Result:      result,
+			MatchedRule: matchedRule,
+		}
+		if model != nil {
+			record.Model = model.String()
+		}
+		p.recordAudit(c, record)
+	}
 
-	data, err_database := p.DbConnector.GetDataFromDB(collName, filter)
+	data, err_database := p.lookupEquipmentStatus(collName, keys, supi, gpsi)
+	var matchedRule string
+	// stored is set when the status is the one of a record, a status derived from a rule, a default or the clone
+	// detection has no record
+	stored := err_database == nil
+	if stored {
+		matchedRule = fmt.Sprintf("record %v", data["pei"])
+	}
+	if err_database != nil && err_database.Cause == "DATA_NOT_FOUND" {
+		rule, err_rule := p.lookupTacRule(colls.TacRules, pei)
+		if err_rule != nil {
+			err_database = err_rule
+		} else if rule != nil {
+			logger.ProcLog.Infof("The Equipment Status of [%s] is matched by the TAC rule of [%s] [%s-%s]",
+				pei, rule.Tac, rule.SnrStart, rule.SnrEnd)
+			data, err_database = map[string]interface{}{
+				"pei":              pei,
+				"supi":             supi,
+				"gpsi":             gpsi,
+				"equipment_status": rule.EquipmentStatus,
+				"reason":           rule.Reason,
+			}, nil
+			matchedRule = tacRuleDescription(rule.Tac, rule.SnrStart, rule.SnrEnd)
+		}
+	}
+	if err_database != nil && err_database.Cause == "DATA_NOT_FOUND" && configuration.UnallocatedTacStatus != "" &&
+		err_model == nil && model == nil && hasTac(pei) {
+		logger.ProcLog.Warnf("The TAC of [%s] isn't allocated, the %s status is returned",
+			pei, configuration.UnallocatedTacStatus)
+		data, err_database = map[string]interface{}{
+			"pei":              pei,
+			"supi":             supi,
+			"gpsi":             gpsi,
+			"equipment_status": configuration.UnallocatedTacStatus,
+		}, nil
+		matchedRule = "unallocated-tac"
+	}
 	if err_database == nil {
+		status := data["equipment_status"].(string)
+		if status == factory.EquipmentStatusWhitelisted && autoGreylisted {
+			logger.ProcLog.Warnf("The cloned [%s] is grey-listed", pei)
+			status = factory.EquipmentStatusGreylisted
+			data["reason"] = factory.CloneDetectionReason
+			matchedRule += ", clone-detection"
+			// The grey-listing isn't the one of the record, the escalation mustn't overwrite the record
+			stored = false
+		}
+		if status == factory.EquipmentStatusGreylisted {
+			status = p.applyGreylistPolicy(c, collName, data, stored)
+		}
+		logger.ProcLog.Infof("The Equipment Status of [%s] %s is %s", pei, modelDescription(model), status)
+		metrics.EquipmentStatusQueries.WithLabelValues(status).Inc()
+		auditCheck(status, matchedRule)
+		span.SetAttributes(attribute.String("eir.equipment_status", status))
 		response := util.ToBsonM(eir_api_service.EIREquipmentStatusGetResponse{
-			Status: data["equipment_status"].(string),
+			Status: status,
 		})
 		c.JSON(http.StatusOK, response)
 	} else {
 		switch err_database.Cause {
 		case "DATA_NOT_FOUND":
-			if defaultStatus := p.App.Config().Configuration.DefaultStatus; defaultStatus != "" {
-				logger.ProcLog.Warnf("The Equipment Status wasn't found, the default %s is returned", defaultStatus)
+			if defaultStatus := p.App.Config().GetDefaultStatus(); defaultStatus != "" {
+				logger.ProcLog.Warnf("The Equipment Status of [%s] %s wasn't found, the default %s is returned",
+					pei, modelDescription(model), defaultStatus)
+				matchedRule = "default-status"
+				if defaultStatus == factory.EquipmentStatusWhitelisted && autoGreylisted {
+					logger.ProcLog.Warnf("The cloned [%s] is grey-listed", pei)
+					defaultStatus = p.applyGreylistPolicy(c, collName, map[string]interface{}{
+						"pei":    pei,
+						"supi":   supi,
+						"gpsi":   gpsi,
+						"reason": factory.CloneDetectionReason,
+					}, false)
+					matchedRule += ", clone-detection"
+				}
+				metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultDefault).Inc()
+				auditCheck(defaultStatus, matchedRule)
+				span.SetAttributes(attribute.String("eir.equipment_status", defaultStatus))
 				response := util.ToBsonM(eir_api_service.EIREquipmentStatusGetResponse{
 					Status: defaultStatus,
 				})
 				c.JSON(http.StatusOK, response)
 			} else {
 				logger.ProcLog.Errorln("The Equipment Status wasn't found")
+				metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultNotFound).Inc()
+				auditCheck(metrics.ResultNotFound, "")
 				problemDetail := models.ProblemDetails{
 					Title:  "The equipment identify checking has failed",
 					Status: http.StatusNotFound,
@@ -50,6 +230,9 @@ func (p *Processor) GetEirEquipmentStatusProcedure(c *gin.Context, collName stri
 			}
 		case "SYSTEM_FAILURE":
 			logger.ProcLog.Errorf("The database has failed with [%v]", err_database.Detail)
+			metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultError).Inc()
+			auditCheck(metrics.ResultError, "")
+			span.SetStatus(codes.Error, err_database.Detail)
 			problemDetail := models.ProblemDetails{
 				Title:  "The equipment identify checking has failed",
 				Status: http.StatusInternalServerError,
@@ -59,6 +242,9 @@ func (p *Processor) GetEirEquipmentStatusProcedure(c *gin.Context, collName stri
 			c.JSON(http.StatusInternalServerError, problemDetail)
 		default:
 			logger.ProcLog.Errorf("The NF has a unspecified failure with [%+v]", err_database)
+			metrics.EquipmentStatusQueries.WithLabelValues(metrics.ResultError).Inc()
+			auditCheck(metrics.ResultError, "")
+			span.SetStatus(codes.Error, err_database.Detail)
 			problemDetail := models.ProblemDetails{
 				Title:  "The equipment identify checking has failed",
 				Status: http.StatusInternalServerError,
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"encoding/json"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/tac"
+	"github.com/adjivas/eir/internal/util"
+	"github.com/free5gc/openapi/models"
+)
+
+// lookupTacModel returns the device model of the TAC of the PEI, the model is nil when the PEI has no TAC
+// or when the TAC isn't allocated
+func (p *Processor) lookupTacModel(collName string, pei string) (*tac.Model, *models.ProblemDetails) {
+	parsed, err := util.ParsePei(pei)
+	if err != nil || parsed.Tac() == "" {
+		return nil, nil
+	}
+
+	data, errDatabase := p.DbConnector.GetDataFromDB(collName, map[string]interface{}{"tac": parsed.Tac()})
+	if errDatabase != nil {
+		if errDatabase.Cause == "DATA_NOT_FOUND" {
+			return nil, nil
+		}
+		return nil, errDatabase
+	}
+
+	var model tac.Model
+	content, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(content, &model)
+	}
+	if err != nil {
+		logger.ProcLog.Warnf("The TAC model %v is malformed: %+v", data, err)
+		return nil, nil
+	}
+	return &model, nil
+}
+
+// hasTac reports whether the PEI is an IMEI or an IMEISV
+func hasTac(pei string) bool {
+	parsed, err := util.ParsePei(pei)
+	return err == nil && parsed.Tac() != ""
+}
+
+// modelDescription describes the device model in the logs
+func modelDescription(model *tac.Model) string {
+	if model == nil {
+		return "of an unknown device model"
+	}
+	return "of a " + model.String()
+}
@@ -0,0 +1,253 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/adjivas/eir/internal/audit"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/util"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/gin-gonic/gin"
+)
+
+// TacRule applies an Equipment Status to every PEI of a TAC, or to the PEIs of a SNR range of the TAC
+type TacRule struct {
+	Tac             string `json:"tac"`
+	SnrStart        string `json:"snr_start,omitempty"` // first SNR of the range, both bounds are included
+	SnrEnd          string `json:"snr_end,omitempty"`   // last SNR of the range
+	EquipmentStatus string `json:"equipment_status"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// tacRuleFilter matches exactly one rule: a whole TAC rule has no SNR range
+func tacRuleFilter(tac string, snrStart string, snrEnd string) map[string]interface{} {
+	filter := map[string]interface{}{
+		"tac":       tac,
+		"snr_start": nil,
+		"snr_end":   nil,
+	}
+	if snrStart != "" {
+		filter["snr_start"] = snrStart
+	}
+	if snrEnd != "" {
+		filter["snr_end"] = snrEnd
+	}
+	return filter
+}
+
+func (r *TacRule) toReplacementMap() map[string]interface{} {
+	data := map[string]interface{}{
+		"tac":              r.Tac,
+		"snr_start":        nil,
+		"snr_end":          nil,
+		"equipment_status": r.EquipmentStatus,
+		"reason":           nil,
+	}
+	if r.SnrStart != "" {
+		data["snr_start"] = r.SnrStart
+		data["snr_end"] = r.SnrEnd
+	}
+	if r.Reason != "" {
+		data["reason"] = r.Reason
+	}
+	return data
+}
+
+// validate completes the rule with the identity of the resource and checks that nothing contradicts it
+func (r *TacRule) validate(tac string, snrStart string, snrEnd string) []models.InvalidParam {
+	var invalidParams []models.InvalidParam
+
+	if r.Tac == "" {
+		r.Tac = tac
+	} else if r.Tac != tac {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "tac",
+			Reason: "The TAC doesn't match the resource",
+		})
+	}
+	if r.SnrStart == "" {
+		r.SnrStart = snrStart
+	} else if r.SnrStart != snrStart {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "snr_start",
+			Reason: "The SNR range start doesn't match the resource",
+		})
+	}
+	if r.SnrEnd == "" {
+		r.SnrEnd = snrEnd
+	} else if r.SnrEnd != snrEnd {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "snr_end",
+			Reason: "The SNR range end doesn't match the resource",
+		})
+	}
+	if !util.IsDigits(r.Tac, util.TacLength) {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "tac",
+			Reason: fmt.Sprintf("The TAC isn't %d digits", util.TacLength),
+		})
+	}
+	if r.SnrStart != "" || r.SnrEnd != "" {
+		if !util.IsDigits(r.SnrStart, util.SnrLength) || !util.IsDigits(r.SnrEnd, util.SnrLength) {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  "snr_start",
+				Reason: fmt.Sprintf("The SNR range bounds aren't both %d digits", util.SnrLength),
+			})
+		} else if r.SnrStart > r.SnrEnd {
+			invalidParams = append(invalidParams, models.InvalidParam{
+				Param:  "snr_end",
+				Reason: "The SNR range ends before it starts",
+			})
+		}
+	}
+	if !factory.IsValidEquipmentStatus(r.EquipmentStatus) {
+		invalidParams = append(invalidParams, models.InvalidParam{
+			Param:  "equipment_status",
+			Reason: fmt.Sprintf("The Equipment Status [%s] isn't one of %v", r.EquipmentStatus, factory.EquipmentStatuses),
+		})
+	}
+
+	return invalidParams
+}
+
+// covers reports whether the rule applies to the SNR, the SNRs have the same length so they're compared as strings
+func (r *TacRule) covers(snr string) bool {
+	return r.SnrStart == "" || (r.SnrStart <= snr && snr <= r.SnrEnd)
+}
+
+// narrowerThan reports whether the rule is more specific than the other one, a range is narrower than a whole TAC
+func (r *TacRule) narrowerThan(other *TacRule) bool {
+	if r.SnrStart == "" {
+		return false
+	}
+	if other.SnrStart == "" {
+		return true
+	}
+	return snrRangeWidth(r) < snrRangeWidth(other)
+}
+
+func snrRangeWidth(r *TacRule) int {
+	start, _ := strconv.Atoi(r.SnrStart)
+	end, _ := strconv.Atoi(r.SnrEnd)
+	return end - start
+}
+
+// lookupTacRule returns the narrowest rule covering the PEI, or nil when the PEI has no TAC or no rule covers it
+func (p *Processor) lookupTacRule(collName string, pei string) (*TacRule, *models.ProblemDetails) {
+	parsed, err := util.ParsePei(pei)
+	if err != nil || parsed.Tac() == "" {
+		return nil, nil
+	}
+
+	documents, errDatabase := p.DbConnector.GetManyDataFromDB(collName, map[string]interface{}{"tac": parsed.Tac()})
+	if errDatabase != nil {
+		return nil, errDatabase
+	}
+
+	var matched *TacRule
+	for _, data := range documents {
+		var rule TacRule
+		content, err := json.Marshal(data)
+		if err == nil {
+			err = json.Unmarshal(content, &rule)
+		}
+		if err != nil {
+			logger.ProcLog.Warnf("The TAC rule %v is malformed: %+v", data, err)
+			continue
+		}
+		if rule.covers(parsed.Snr()) && (matched == nil || rule.narrowerThan(matched)) {
+			matched = &rule
+		}
+	}
+	return matched, nil
+}
+
+func (p *Processor) ListTacRulesProcedure(c *gin.Context, collName string, tac string) {
+	documents, errDatabase := p.DbConnector.GetManyDataFromDB(collName, map[string]interface{}{"tac": tac})
+	if errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	rules := []TacRule{}
+	for _, data := range documents {
+		var rule TacRule
+		content, err := json.Marshal(data)
+		if err == nil {
+			err = json.Unmarshal(content, &rule)
+		}
+		if err != nil {
+			provisioningDatabaseFailure(c, &models.ProblemDetails{Detail: err.Error()})
+			return
+		}
+		rules = append(rules, rule)
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (p *Processor) ReplaceTacRuleProcedure(c *gin.Context, collName string,
+	tac string, snrStart string, snrEnd string, rule TacRule,
+) {
+	if invalidParams := rule.validate(tac, snrStart, snrEnd); len(invalidParams) > 0 {
+		provisioningBadRequest(c, "The TAC rule is invalid", invalidParams)
+		return
+	}
+
+	filter := tacRuleFilter(tac, snrStart, snrEnd)
+	existed, errDatabase := p.DbConnector.PutDataToDB(collName, filter, rule.toReplacementMap())
+	if errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The TAC rule of [%s] [%s-%s] is replaced by %s", tac, snrStart, snrEnd, rule.EquipmentStatus)
+	p.recordAudit(c, audit.Record{
+		Event:       audit.EventReplaceTacRule,
+		Result:      rule.EquipmentStatus,
+		MatchedRule: tacRuleDescription(tac, snrStart, snrEnd),
+	})
+	if existed {
+		c.JSON(http.StatusOK, rule)
+	} else {
+		c.Header("Location", c.Request.URL.String())
+		c.JSON(http.StatusCreated, rule)
+	}
+}
+
+func (p *Processor) DeleteTacRuleProcedure(c *gin.Context, collName string,
+	tac string, snrStart string, snrEnd string,
+) {
+	filter := tacRuleFilter(tac, snrStart, snrEnd)
+	if _, errDatabase := p.DbConnector.GetDataFromDB(collName, filter); errDatabase != nil {
+		if errDatabase.Cause == "DATA_NOT_FOUND" {
+			logger.ProcLog.Errorln("The TAC rule wasn't found")
+			problemDetail := models.ProblemDetails{
+				Title:  provisioningFailedTitle,
+				Status: http.StatusNotFound,
+				Detail: "The TAC rule wasn't found",
+				Cause:  "DATA_NOT_FOUND",
+			}
+			c.JSON(http.StatusNotFound, problemDetail)
+		} else {
+			provisioningDatabaseFailure(c, errDatabase)
+		}
+		return
+	}
+
+	if errDatabase := p.DbConnector.DeleteDataFromDB(collName, filter); errDatabase != nil {
+		provisioningDatabaseFailure(c, errDatabase)
+		return
+	}
+
+	logger.ProcLog.Infof("The TAC rule of [%s] [%s-%s] is deleted", tac, snrStart, snrEnd)
+	p.recordAudit(c, audit.Record{
+		Event:       audit.EventDeleteTacRule,
+		Result:      audit.ResultDeleted,
+		MatchedRule: tacRuleDescription(tac, snrStart, snrEnd),
+	})
+	c.Status(http.StatusNoContent)
+}
@@ -1,6 +1,9 @@
 package sbi
 
 import (
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/util"
+	"github.com/free5gc/openapi/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,6 +17,9 @@ type Route struct {
 	Pattern string
 	// HandlerFunc is the handler function of this route.
 	HandlerFunc gin.HandlerFunc
+	// Scope is the OAuth2 scope required by this route when the NRF requires OAuth2,
+	// the routes without scope aren't authorized.
+	Scope models.ServiceName
 }
 
 type RouteGroup interface {
@@ -23,17 +29,22 @@ type RouteGroup interface {
 func AddService(group *gin.RouterGroup, routes []Route) {
 	group.Use(URILengthLimiter())
 	for _, route := range routes {
+		var handlers []gin.HandlerFunc
+		if route.Scope != "" {
+			handlers = append(handlers, util.NewRouterAuthorizationCheck(route.Scope).Middleware(eir_context.GetSelf()))
+		}
+		handlers = append(handlers, route.HandlerFunc)
 		switch route.Method {
 		case "GET":
-			group.GET(route.Pattern, route.HandlerFunc)
+			group.GET(route.Pattern, handlers...)
 		case "PATCH":
-			group.PATCH(route.Pattern, route.HandlerFunc)
+			group.PATCH(route.Pattern, handlers...)
 		case "POST":
-			group.POST(route.Pattern, route.HandlerFunc)
+			group.POST(route.Pattern, handlers...)
 		case "PUT":
-			group.PUT(route.Pattern, route.HandlerFunc)
+			group.PUT(route.Pattern, handlers...)
 		case "DELETE":
-			group.DELETE(route.Pattern, route.HandlerFunc)
+			group.DELETE(route.Pattern, handlers...)
 		}
 	}
 }
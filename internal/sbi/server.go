@@ -2,26 +2,38 @@ package sbi
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"net/netip"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adjivas/eir/internal/logger"
 	processor "github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/internal/tracing"
 	"github.com/adjivas/eir/pkg/app"
 	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/util/httpwrapper"
 	logger_util "github.com/free5gc/util/logger"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Server struct {
 	eir EIR
 
 	httpServer *http.Server
-	router     *gin.Engine
+	// probeServer serves the probes in plain HTTP, it's nil without the probes listener
+	probeServer  *http.Server
+	router       *gin.Engine
+	certificates *certificateReloader
+	// listening reports whether the SBI listener is bound and not shut down
+	listening atomic.Bool
 }
 
 type EIR interface {
@@ -38,6 +50,7 @@ func NewServer(eir EIR, tlsKeyLogPath string) *Server {
 	s.router = newRouter(s)
 	server, err := bindRouter(eir, s.router, tlsKeyLogPath)
 	s.httpServer = server
+	s.probeServer = newProbeServer(s)
 
 	if err != nil {
 		logger.SBILog.Errorf("bind Router Error: %+v", err)
@@ -58,23 +71,43 @@ func (s *Server) Run(wg *sync.WaitGroup) {
 		}
 		logger.SBILog.Infof("SBI server (listen on %s) stopped", s.httpServer.Addr)
 	}()
+
+	if s.probeServer == nil {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		logger.SBILog.Infof("Probes server listens on %s", s.probeServer.Addr)
+		if err := s.probeServer.ListenAndServe(); err != http.ErrServerClosed {
+			logger.SBILog.Errorf("Probes server failed: %+v", err)
+			return
+		}
+		logger.SBILog.Infof("Probes server (listen on %s) stopped", s.probeServer.Addr)
+	}()
 }
 
 func (s *Server) Shutdown() {
-	s.shutdownHttpServer()
+	s.listening.Store(false)
+	shutdownHttpServer(s.httpServer)
+	shutdownHttpServer(s.probeServer)
+	if s.certificates != nil {
+		s.certificates.stop()
+	}
 }
 
-func (s *Server) shutdownHttpServer() {
+func shutdownHttpServer(server *http.Server) {
 	const shutdownTimeout time.Duration = 2 * time.Second
 
-	if s.httpServer == nil {
+	if server == nil {
 		return
 	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	err := s.httpServer.Shutdown(shutdownCtx)
+	err := server.Shutdown(shutdownCtx)
 	if err != nil {
 		logger.SBILog.Errorf("HTTP server shutdown failed: %+v", err)
 	}
@@ -92,21 +125,118 @@ func bindRouter(eir app.App, router *gin.Engine, tlsKeyLogPath string) (*http.Se
 	bindAddr := netip.AddrPortFrom(addr, uint16(port)).String()
 
 	logger.SBILog.Infof("Binding addr: [%s]", bindAddr)
-	return httpwrapper.NewHttp2Server(bindAddr, tlsKeyLogPath, router)
+	server, err := httpwrapper.NewHttp2Server(bindAddr, tlsKeyLogPath, router)
+	if err != nil {
+		return nil, err
+	}
+	if err = configureClientAuth(server, sbiConfig.Tls); err != nil {
+		logger.SBILog.Errorf("The client authentication can't be configured: %+v", err)
+		return nil, err
+	}
+	return server, nil
 }
 
 func newRouter(s *Server) *gin.Engine {
 	router := logger_util.NewGinWithLogrus(logger.GinLog)
+	// The server spans continue the trace context of the incoming traceparent
+	router.Use(otelgin.Middleware(tracing.ServiceName))
+	router.Use(httpMetrics())
+
+	var allowList []*factory.ClientIdentity
+	allowAmfPeers := false
+	if tls := s.eir.Config().Configuration.Sbi.Tls; tls != nil {
+		allowList = tls.ClientAllowList
+		allowAmfPeers = tls.AllowAmfPeers
+	}
 
 	eirHttpCallBackGroup := router.Group(factory.EirDrResUriPrefix)
 	equipmentStatusRoutes := s.getEquipmentStatusRoutes()
+	if check := clientIdentityCheck(eirHttpCallBackGroup, equipmentStatusRoutes, allowList, allowAmfPeers); check != nil {
+		eirHttpCallBackGroup.Use(check)
+	}
+	if check := s.registrationCheck(); check != nil {
+		eirHttpCallBackGroup.Use(check)
+	}
 	AddService(eirHttpCallBackGroup, equipmentStatusRoutes)
 
+	provisioningGroup := router.Group(factory.EirProvResUriPrefix)
+	provisioningRoutes := s.getProvisioningRoutes()
+	if check := clientIdentityCheck(provisioningGroup, provisioningRoutes, allowList, false); check != nil {
+		provisioningGroup.Use(check)
+	}
+	AddService(provisioningGroup, provisioningRoutes)
+
+	callbackGroup := router.Group(factory.EirCallbackResUriPrefix)
+	callbackRoutes := s.getCallbackRoutes()
+	if check := clientIdentityCheck(callbackGroup, callbackRoutes, allowList, false); check != nil {
+		callbackGroup.Use(check)
+	}
+	AddService(callbackGroup, callbackRoutes)
+
+	// The probes aren't authorized, they're called by the orchestrator
+	AddService(router.Group(""), s.getHealthRoutes())
+
 	return router
 }
 
+// newProbeServer returns the plain HTTP server of the probes, it's nil without the probes listener. It isn't
+// configured with the TLS of the SBI, so the orchestrator reaches the probes without a client certificate.
+func newProbeServer(s *Server) *http.Server {
+	probes := s.eir.Config().Configuration.Probes
+	if probes == nil {
+		return nil
+	}
+
+	router := logger_util.NewGinWithLogrus(logger.GinLog)
+	AddService(router.Group(""), s.getHealthRoutes())
+	return &http.Server{
+		Addr:              net.JoinHostPort(probes.BindingIP, strconv.Itoa(probes.Port)),
+		Handler:           router,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// registrationCheck refuses the equipment identity checks while the EIR isn't registered to the NRF,
+// it's nil when the EIR is configured to serve anyway
+func (s *Server) registrationCheck() gin.HandlerFunc {
+	nrfRegistration := s.eir.Config().Configuration.NrfRegistration
+	if nrfRegistration == nil || nrfRegistration.Unreachable != factory.NrfUnreachableRefuse {
+		return nil
+	}
+
+	return func(c *gin.Context) {
+		if s.eir.Context().IsRegistered() {
+			c.Next()
+			return
+		}
+
+		logger.SBILog.Warnf("The request is refused, the EIR isn't registered to the NRF")
+		problemDetail := models.ProblemDetails{
+			Title:  "Service unavailable",
+			Status: http.StatusServiceUnavailable,
+			Detail: "The EIR isn't registered to the NRF",
+			Cause:  "NF_NOT_REGISTERED",
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, problemDetail)
+	}
+}
+
+// listen binds the SBI listener, the readiness reports it from then
+func (s *Server) listen() (net.Listener, error) {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.listening.Store(true)
+	return listener, nil
+}
+
 func (s *Server) unsecureServe() error {
-	return s.httpServer.ListenAndServe()
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.httpServer.Serve(listener)
 }
 
 func (s *Server) secureServe() error {
@@ -122,7 +252,22 @@ func (s *Server) secureServe() error {
 		keyPath = factory.EirDefaultPrivateKeyPath
 	}
 
-	return s.httpServer.ListenAndServeTLS(pemPath, keyPath)
+	certificates, err := newCertificateReloader(pemPath, keyPath)
+	if err != nil {
+		return err
+	}
+	s.certificates = certificates
+	s.certificates.watch()
+
+	if s.httpServer.TLSConfig == nil {
+		s.httpServer.TLSConfig = &tls.Config{}
+	}
+	s.httpServer.TLSConfig.GetCertificate = s.certificates.GetCertificate
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.httpServer.ServeTLS(listener, "", "")
 }
 
 func (s *Server) serve() error {
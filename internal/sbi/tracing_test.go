@@ -0,0 +1,48 @@
+package sbi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adjivas/eir/internal/tracing"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestEIR_EquipmentStatus_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	_, err := tracing.Init(nil, "")
+	require.Nil(t, err)
+
+	s := setupServerWithMemory(t, `policyData.ues.eirData:
+  - pei: imei-012345678901237
+    equipment_status: BLACKLISTED
+`, factory.Configuration{})
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+		factory.EirDrResUriPrefix+"/equipment-status?pei=imei-012345678901237", nil)
+	require.Nil(t, err)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	rsp := httptest.NewRecorder()
+	s.router.ServeHTTP(rsp, req)
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	names := make(map[string]bool)
+	for _, span := range exporter.GetSpans() {
+		assert.Equal(t, traceId, span.SpanContext.TraceID().String())
+		names[span.Name] = true
+	}
+	assert.True(t, names[factory.EirDrResUriPrefix+"/equipment-status"])
+	assert.True(t, names["GetEirEquipmentStatusProcedure"])
+	assert.True(t, names["DbConnector.GetDataFromDB"])
+}
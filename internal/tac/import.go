@@ -0,0 +1,209 @@
+package tac
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/util"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ModelCollName is the collection of the device models
+const ModelCollName = "policyData.ues.eirTacModels"
+
+// importBatchSize is the count of models written by each PutManyDataToDB
+const importBatchSize = 1000
+
+// Model is the device model allocated to a TAC by the GSMA
+type Model struct {
+	Tac        string `json:"tac"`
+	Brand      string `json:"brand,omitempty"`
+	Model      string `json:"model,omitempty"`
+	DeviceType string `json:"device_type,omitempty"`
+}
+
+func (m *Model) String() string {
+	description := strings.TrimSpace(m.Brand + " " + m.Model)
+	if m.DeviceType != "" {
+		description += " (" + m.DeviceType + ")"
+	}
+	return description
+}
+
+func (m *Model) toMap() map[string]interface{} {
+	data := map[string]interface{}{
+		"tac":         m.Tac,
+		"brand":       nil,
+		"model":       nil,
+		"device_type": nil,
+	}
+	if m.Brand != "" {
+		data["brand"] = m.Brand
+	}
+	if m.Model != "" {
+		data["model"] = m.Model
+	}
+	if m.DeviceType != "" {
+		data["device_type"] = m.DeviceType
+	}
+	return data
+}
+
+// headerAliases are the header names of each field in the GSMA exports, the first one found is used
+var headerAliases = map[string][]string{
+	"tac":         {"tac"},
+	"brand":       {"brand name", "brand", "manufacturer (or) applicant", "manufacturer"},
+	"model":       {"model name", "model", "marketing name"},
+	"device_type": {"device type", "devicetype", "equipment type"},
+}
+
+// Reader reads the models of a GSMA TAC database export
+type Reader struct {
+	csv     *csv.Reader
+	columns map[string]int
+}
+
+// NewReader reads the header of the export, the delimiter is a tab or a semicolon when the header has one,
+// else a comma
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.ReadString('\n')
+	if err != nil && (err != io.EOF || header == "") {
+		return nil, fmt.Errorf("the TAC export header can't be read: %+v", err)
+	}
+
+	delimiter := ','
+	if strings.Contains(header, "\t") {
+		delimiter = '\t'
+	} else if strings.Contains(header, ";") {
+		delimiter = ';'
+	}
+
+	headerReader := csv.NewReader(strings.NewReader(strings.TrimPrefix(header, "\ufeff")))
+	headerReader.Comma = delimiter
+	names, err := headerReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("the TAC export header is malformed: %+v", err)
+	}
+
+	indexes := make(map[string]int)
+	for i, name := range names {
+		indexes[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	columns := make(map[string]int)
+	for field, aliases := range headerAliases {
+		for _, alias := range aliases {
+			if i, ok := indexes[alias]; ok {
+				columns[field] = i
+				break
+			}
+		}
+	}
+	if _, ok := columns["tac"]; !ok {
+		return nil, fmt.Errorf("the TAC export header has no TAC column: %v", names)
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return &Reader{
+		csv:     reader,
+		columns: columns,
+	}, nil
+}
+
+func (r *Reader) field(record []string, field string) string {
+	i, ok := r.columns[field]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// Read returns the next model, or io.EOF at the end of the export
+func (r *Reader) Read() (*Model, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	tac, err := normalizeTac(r.field(record, "tac"))
+	if err != nil {
+		// The header is read apart, so the lines of the CSV reader start after it
+		line, _ := r.csv.FieldPos(0)
+		return nil, fmt.Errorf("the line %d: %+v", line+1, err)
+	}
+	return &Model{
+		Tac:        tac,
+		Brand:      r.field(record, "brand"),
+		Model:      r.field(record, "model"),
+		DeviceType: r.field(record, "device_type"),
+	}, nil
+}
+
+// normalizeTac restores the leading zeros dropped by the spreadsheets
+func normalizeTac(tac string) (string, error) {
+	if tac == "" || len(tac) > util.TacLength {
+		return "", fmt.Errorf("the TAC [%s] isn't %d digits", tac, util.TacLength)
+	}
+	for _, c := range tac {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("the TAC [%s] isn't %d digits", tac, util.TacLength)
+		}
+	}
+	return strings.Repeat("0", util.TacLength-len(tac)) + tac, nil
+}
+
+// Import stores the models of the export through the DbConnector, the malformed lines are skipped.
+// It returns the counts of imported and skipped lines.
+func Import(dbConnector database.DbConnector, collName string, r io.Reader) (int, int, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	imported, skipped := 0, 0
+	var filters []bson.M
+	var data []map[string]interface{}
+	flush := func() error {
+		if len(filters) == 0 {
+			return nil
+		}
+		if problemDetails := dbConnector.PutManyDataToDB(collName, filters, data); problemDetails != nil {
+			return fmt.Errorf("the TAC models can't be stored: %s", problemDetails.Detail)
+		}
+		imported += len(filters)
+		filters, data = nil, nil
+		return nil
+	}
+
+	for {
+		model, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return imported, skipped, fmt.Errorf("the TAC export is malformed: %+v", err)
+			}
+			logger.DbLog.Warnf("The TAC model is skipped: %+v", err)
+			skipped++
+			continue
+		}
+
+		filters = append(filters, bson.M{"tac": model.Tac})
+		data = append(data, model.toMap())
+		if len(filters) >= importBatchSize {
+			if err = flush(); err != nil {
+				return imported, skipped, err
+			}
+		}
+	}
+	return imported, skipped, flush()
+}
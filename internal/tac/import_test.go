@@ -0,0 +1,83 @@
+package tac
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/adjivas/eir/internal/database/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		export string
+	}{
+		{
+			name: "CSV",
+			export: "TAC,Marketing Name,Brand Name,Model Name,Device Type\n" +
+				"35209900,Galaxy,Acme,\"A-100, Pro\",Smartphone\n" +
+				"1234567,Modem,Acme,M-1,Modem\n",
+		},
+		{
+			name: "TSV",
+			export: "TAC\tBrand Name\tModel Name\tDevice Type\n" +
+				"35209900\tAcme\tA-100, Pro\tSmartphone\n" +
+				"1234567\tAcme\tM-1\tModem\n",
+		},
+		{
+			name: "Semicolon",
+			export: "\ufefftac;brand;model;device type\r\n" +
+				"35209900;Acme;A-100, Pro;Smartphone\r\n" +
+				"1234567;Acme;M-1;Modem",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(tt.export))
+			require.Nil(t, err)
+
+			model, err := reader.Read()
+			require.Nil(t, err)
+			assert.Equal(t, Model{Tac: "35209900", Brand: "Acme", Model: "A-100, Pro", DeviceType: "Smartphone"}, *model)
+
+			model, err = reader.Read()
+			require.Nil(t, err)
+			assert.Equal(t, Model{Tac: "01234567", Brand: "Acme", Model: "M-1", DeviceType: "Modem"}, *model)
+
+			_, err = reader.Read()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReaderWithoutTacColumn(t *testing.T) {
+	_, err := NewReader(strings.NewReader("Brand Name,Model Name\nAcme,A-100\n"))
+	assert.NotNil(t, err)
+}
+
+func TestImport(t *testing.T) {
+	m, err := memory.NewMemoryDbConnector(nil)
+	require.Nil(t, err)
+
+	imported, skipped, err := Import(m, ModelCollName, strings.NewReader("TAC,Brand Name,Model Name,Device Type\n"+
+		"35209900,Acme,A-100,Smartphone\n"+
+		"3520990A,Acme,A-200,Smartphone\n"+
+		"35209901,Acme,A-300,Tablet\n"+
+		"35209900,Acme,A-100 Pro,Smartphone\n"))
+	require.Nil(t, err)
+	assert.Equal(t, 3, imported)
+	assert.Equal(t, 1, skipped)
+
+	data, problemDetails := m.GetDataFromDB(ModelCollName, bson.M{"tac": "35209900"})
+	require.Nil(t, problemDetails)
+	assert.Equal(t, "A-100 Pro", data["model"])
+
+	models, problemDetails := m.GetManyDataFromDB(ModelCollName, bson.M{})
+	require.Nil(t, problemDetails)
+	assert.Len(t, models, 2)
+}
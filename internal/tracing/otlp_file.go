@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// otlpFileClient appends the spans to a file in the OTLP JSON format, one TracesData per line,
+// as read by the otlpjsonfile receiver of the OpenTelemetry collector
+type otlpFileClient struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newOtlpFileClient(path string) *otlpFileClient {
+	return &otlpFileClient{path: path}
+}
+
+func (c *otlpFileClient) Start(context.Context) error {
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.file = file
+	return nil
+}
+
+func (c *otlpFileClient) Stop(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+func (c *otlpFileClient) UploadTraces(_ context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	content, err := protojson.Marshal(&tracepb.TracesData{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	// The OTLP JSON encodes the trace and span IDs in hexadecimal instead of the base64 of the protobuf JSON
+	var traces interface{}
+	if err = json.Unmarshal(content, &traces); err != nil {
+		return err
+	}
+	if err = hexIds(traces); err != nil {
+		return err
+	}
+	content, err = json.Marshal(traces)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return os.ErrClosed
+	}
+	_, err = c.file.Write(append(content, '\n'))
+	return err
+}
+
+// hexIds encodes the trace and span IDs of the JSON value in hexadecimal
+func hexIds(value interface{}) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			id, ok := field.(string)
+			if !ok || (key != "traceId" && key != "spanId" && key != "parentSpanId") {
+				if err := hexIds(field); err != nil {
+					return err
+				}
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(id)
+			if err != nil {
+				return err
+			}
+			value[key] = hex.EncodeToString(decoded)
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := hexIds(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName is the service of the spans of the EIR
+	ServiceName = "eir"
+	// instrumentationName is the tracer name of the spans created by the EIR
+	instrumentationName = "github.com/adjivas/eir"
+)
+
+// Tracer returns the tracer of the EIR, its spans are exported once Init is called
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init propagates the W3C trace context and exports the spans with the configured exporter,
+// the spans aren't exported without configuration. It returns the shutdown flushing the spans.
+func Init(config *factory.Tracing, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if config == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case factory.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case factory.TracingExporterOtlpFile:
+		exporter, err = otlptrace.New(context.Background(), newOtlpFileClient(config.Path))
+	default:
+		err = fmt.Errorf("unsupported tracing exporter: %s", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(ServiceName),
+			semconv.ServiceVersion(version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	logger.InitLog.Infof("The spans are exported by the %s exporter", config.Exporter)
+	return provider.Shutdown, nil
+}
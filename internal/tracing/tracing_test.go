@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestInitOtlpFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Init(&factory.Tracing{Exporter: factory.TracingExporterOtlpFile, Path: path}, "1.1.0")
+	require.Nil(t, err)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	_, span := Tracer().Start(context.Background(), "GetEirEquipmentStatusProcedure")
+	traceId := span.SpanContext().TraceID().String()
+	span.End()
+	require.Nil(t, shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+
+	var traces struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceId string `json:"traceId"`
+					Name    string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.Nil(t, json.Unmarshal([]byte(lines[0]), &traces))
+	require.Len(t, traces.ResourceSpans, 1)
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GetEirEquipmentStatusProcedure", spans[0].Name)
+	assert.Equal(t, traceId, spans[0].TraceId)
+}
+
+func TestInitWithoutConfiguration(t *testing.T) {
+	shutdown, err := Init(nil, "1.1.0")
+	require.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
@@ -0,0 +1,179 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PeiType is the format of a Permanent Equipment Identifier according to the TS 29.571 Pei pattern
+type PeiType string
+
+const (
+	PeiTypeImei   PeiType = "imei"
+	PeiTypeImeisv PeiType = "imeisv"
+	PeiTypeMac    PeiType = "mac"
+	PeiTypeEui64  PeiType = "eui64"
+	// peiPrefixEui is accepted as a prefix of the EUI-64
+	peiPrefixEui = "eui"
+	// PeiTypeOther is any other string, the TS 29.571 pattern ends with a free form alternative
+	PeiTypeOther PeiType = "other"
+)
+
+const (
+	// TacLength is the number of digits of a Type Allocation Code
+	TacLength = 8
+	// SnrLength is the number of digits of a Serial Number
+	SnrLength = 6
+
+	imeiLength   = 15
+	imeisvLength = 16
+	macGroups    = 6
+	eui64Groups  = 8
+
+	macUntrustedSuffix = "-untrusted"
+)
+
+// Pei is a parsed Permanent Equipment Identifier
+type Pei struct {
+	Type PeiType
+	// Value is the identifier without its prefix
+	Value string
+	// Untrusted is set on a MAC address which the 5G-RG can't vouch for
+	Untrusted bool
+	// prefix is the prefix written instead of the type, so the PEI is rendered as it was received
+	prefix string
+}
+
+// ParsePei checks the prefix, the length, the digits and the IMEI check digit of a PEI
+func ParsePei(pei string) (*Pei, error) {
+	prefix, value, found := strings.Cut(pei, "-")
+	if !found {
+		return &Pei{Type: PeiTypeOther, Value: pei}, nil
+	}
+
+	switch PeiType(prefix) {
+	case PeiTypeImei:
+		if err := checkDigits(value, imeiLength); err != nil {
+			return nil, fmt.Errorf("the IMEI %s", err.Error())
+		}
+		if checkDigit := LuhnCheckDigit(value[:imeiLength-1]); value[imeiLength-1] != checkDigit {
+			return nil, fmt.Errorf("the IMEI check digit is %c instead of %c", value[imeiLength-1], checkDigit)
+		}
+		return &Pei{Type: PeiTypeImei, Value: value}, nil
+	case PeiTypeImeisv:
+		if err := checkDigits(value, imeisvLength); err != nil {
+			return nil, fmt.Errorf("the IMEISV %s", err.Error())
+		}
+		return &Pei{Type: PeiTypeImeisv, Value: value}, nil
+	case PeiTypeMac:
+		address, untrusted := strings.CutSuffix(value, macUntrustedSuffix)
+		if err := checkHexGroups(address, macGroups); err != nil {
+			return nil, fmt.Errorf("the MAC address %s", err.Error())
+		}
+		return &Pei{Type: PeiTypeMac, Value: address, Untrusted: untrusted}, nil
+	case PeiTypeEui64, peiPrefixEui:
+		if err := checkHexGroups(value, eui64Groups); err != nil {
+			return nil, fmt.Errorf("the EUI-64 %s", err.Error())
+		}
+		parsed := &Pei{Type: PeiTypeEui64, Value: value}
+		if prefix == peiPrefixEui {
+			parsed.prefix = prefix
+		}
+		return parsed, nil
+	default:
+		return &Pei{Type: PeiTypeOther, Value: pei}, nil
+	}
+}
+
+func (p *Pei) String() string {
+	prefix := string(p.Type)
+	if p.prefix != "" {
+		prefix = p.prefix
+	}
+	switch p.Type {
+	case PeiTypeOther:
+		return p.Value
+	case PeiTypeMac:
+		if p.Untrusted {
+			return prefix + "-" + p.Value + macUntrustedSuffix
+		}
+	}
+	return prefix + "-" + p.Value
+}
+
+// Tac returns the Type Allocation Code of an IMEI or an IMEISV
+func (p *Pei) Tac() string {
+	if p.Type != PeiTypeImei && p.Type != PeiTypeImeisv {
+		return ""
+	}
+	return p.Value[:TacLength]
+}
+
+// Snr returns the Serial Number of an IMEI or an IMEISV
+func (p *Pei) Snr() string {
+	if p.Type != PeiTypeImei && p.Type != PeiTypeImeisv {
+		return ""
+	}
+	return p.Value[TacLength : TacLength+SnrLength]
+}
+
+// Imei returns the IMEI of an IMEI or an IMEISV, the check digit is computed from the TAC and the SNR
+func (p *Pei) Imei() string {
+	if p.Type != PeiTypeImei && p.Type != PeiTypeImeisv {
+		return ""
+	}
+	digits := p.Value[:TacLength+SnrLength]
+	return string(PeiTypeImei) + "-" + digits + string(LuhnCheckDigit(digits))
+}
+
+// IsDigits reports whether the value is made of exactly length decimal digits
+func IsDigits(value string, length int) bool {
+	return checkDigits(value, length) == nil
+}
+
+func checkDigits(value string, length int) error {
+	if len(value) != length {
+		return fmt.Errorf("has %d digits instead of %d", len(value), length)
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("has the %q non-digit character", c)
+		}
+	}
+	return nil
+}
+
+func checkHexGroups(value string, groups int) error {
+	parts := strings.Split(value, "-")
+	if len(parts) != groups {
+		return fmt.Errorf("has %d groups instead of %d", len(parts), groups)
+	}
+	for _, part := range parts {
+		if len(part) != 2 {
+			return fmt.Errorf("has the %q group which isn't 2 hexadecimal digits", part)
+		}
+		for _, c := range part {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return fmt.Errorf("has the %q non-hexadecimal character", c)
+			}
+		}
+	}
+	return nil
+}
+
+// LuhnCheckDigit computes the Luhn check digit of the digits
+func LuhnCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		// The rightmost digit is doubled since the check digit will follow it
+		if (len(digits)-1-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePei(t *testing.T) {
+	tests := []struct {
+		name string
+		pei  string
+		want Pei
+	}{
+		{
+			name: "IMEI",
+			pei:  "imei-490154203237518",
+			want: Pei{Type: PeiTypeImei, Value: "490154203237518"},
+		},
+		{
+			name: "IMEISV",
+			pei:  "imeisv-4370816125816151",
+			want: Pei{Type: PeiTypeImeisv, Value: "4370816125816151"},
+		},
+		{
+			name: "MAC",
+			pei:  "mac-00-1A-2b-3c-4d-5e",
+			want: Pei{Type: PeiTypeMac, Value: "00-1A-2b-3c-4d-5e"},
+		},
+		{
+			name: "Untrusted MAC",
+			pei:  "mac-00-1a-2b-3c-4d-5e-untrusted",
+			want: Pei{Type: PeiTypeMac, Value: "00-1a-2b-3c-4d-5e", Untrusted: true},
+		},
+		{
+			name: "EUI-64",
+			pei:  "eui64-00-1a-2b-ff-fe-3c-4d-5e",
+			want: Pei{Type: PeiTypeEui64, Value: "00-1a-2b-ff-fe-3c-4d-5e"},
+		},
+		{
+			name: "EUI-64 with the eui prefix",
+			pei:  "eui-00-1a-2b-ff-fe-3c-4d-5e",
+			want: Pei{Type: PeiTypeEui64, Value: "00-1a-2b-ff-fe-3c-4d-5e", prefix: "eui"},
+		},
+		{
+			name: "Other",
+			pei:  "012345678901234",
+			want: Pei{Type: PeiTypeOther, Value: "012345678901234"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pei, err := ParsePei(tt.pei)
+			require.Nil(t, err)
+			assert.Equal(t, tt.want, *pei)
+			assert.Equal(t, tt.pei, pei.String())
+		})
+	}
+}
+
+func TestParsePeiMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		pei  string
+	}{
+		{
+			name: "Short IMEI",
+			pei:  "imei-42",
+		},
+		{
+			name: "IMEI with a letter",
+			pei:  "imei-49015420323751a",
+		},
+		{
+			name: "IMEI with a wrong check digit",
+			pei:  "imei-490154203237519",
+		},
+		{
+			name: "Long IMEISV",
+			pei:  "imeisv-43708161258161510",
+		},
+		{
+			name: "MAC with 5 groups",
+			pei:  "mac-00-1a-2b-3c-4d",
+		},
+		{
+			name: "MAC with a non-hexadecimal group",
+			pei:  "mac-00-1a-2b-3c-4d-5g",
+		},
+		{
+			name: "EUI-64 with 6 groups",
+			pei:  "eui64-00-1a-2b-3c-4d-5e",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePei(tt.pei)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestPeiTacSnrImei(t *testing.T) {
+	pei, err := ParsePei("imeisv-4370816125816151")
+	require.Nil(t, err)
+	assert.Equal(t, "43708161", pei.Tac())
+	assert.Equal(t, "258161", pei.Snr())
+
+	assert.Equal(t, "imei-437081612581614", pei.Imei())
+
+	pei, err = ParsePei("mac-00-1a-2b-3c-4d-5e")
+	require.Nil(t, err)
+	assert.Equal(t, "", pei.Tac())
+	assert.Equal(t, "", pei.Imei())
+}
+
+func TestLuhnCheckDigit(t *testing.T) {
+	assert.Equal(t, byte('8'), LuhnCheckDigit("49015420323751"))
+	assert.Equal(t, byte('7'), LuhnCheckDigit("01234567890123"))
+	assert.Equal(t, byte('0'), LuhnCheckDigit("00000000000000"))
+}
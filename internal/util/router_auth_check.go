@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"net/http"
 
 	eir_context "github.com/adjivas/eir/internal/context"
@@ -19,15 +20,37 @@ func NewRouterAuthorizationCheck(serviceName models.ServiceName) *RouterAuthoriz
 	}
 }
 
+// Check aborts the request with a 401 when the access token is missing or invalid,
+// and with a 403 when the access token isn't granted the scope of the route
 func (rac *RouterAuthorizationCheck) Check(c *gin.Context, eirContext eir_context.NFContext) {
 	token := c.Request.Header.Get("Authorization")
 	err := eirContext.AuthorizationCheck(token, rac.serviceName)
 	if err != nil {
-		logger.UtilLog.Debugf("RouterAuthorizationCheck: Check Unauthorized: %s", err.Error())
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-		c.Abort()
+		problemDetail := models.ProblemDetails{
+			Title:  "Unauthorized",
+			Status: http.StatusUnauthorized,
+			Detail: err.Error(),
+			Cause:  "INVALID_TOKEN",
+		}
+		authenticate := `Bearer error="invalid_token"`
+		if errors.Is(err, eir_context.ErrInsufficientScope) {
+			problemDetail.Title = "Forbidden"
+			problemDetail.Status = http.StatusForbidden
+			problemDetail.Cause = "INSUFFICIENT_SCOPE"
+			authenticate = `Bearer error="insufficient_scope", scope="` + string(rac.serviceName) + `"`
+		}
+		logger.UtilLog.Debugf("RouterAuthorizationCheck: Check %s: %s", problemDetail.Title, err.Error())
+		c.Header("WWW-Authenticate", authenticate)
+		c.AbortWithStatusJSON(int(problemDetail.Status), problemDetail)
 		return
 	}
 
 	logger.UtilLog.Debugf("RouterAuthorizationCheck: Check Authorized")
 }
+
+// Middleware checks the authorization of the requests before their handler
+func (rac *RouterAuthorizationCheck) Middleware(eirContext eir_context.NFContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rac.Check(c, eirContext)
+	}
+}
@@ -1,18 +1,22 @@
 package util
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	eir_context "github.com/adjivas/eir/internal/context"
 	"github.com/free5gc/openapi/models"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 )
 
 const (
-	Valid   = "valid"
-	Invalid = "invalid"
+	Valid        = "valid"
+	Invalid      = "invalid"
+	Insufficient = "insufficient"
 )
 
 type mockEIRContext struct{}
@@ -25,6 +29,9 @@ func (m *mockEIRContext) AuthorizationCheck(token string, serviceName models.Ser
 	if token == Valid {
 		return nil
 	}
+	if token == Insufficient {
+		return fmt.Errorf("%w: %s", eir_context.ErrInsufficientScope, serviceName)
+	}
 
 	return errors.New("invalid token")
 }
@@ -45,6 +52,7 @@ func TestRouterAuthorizationCheck_Check(t *testing.T) {
 	}
 	type Want struct {
 		statusCode int
+		cause      string
 	}
 
 	tests := []struct {
@@ -68,6 +76,17 @@ func TestRouterAuthorizationCheck_Check(t *testing.T) {
 			},
 			want: Want{
 				statusCode: http.StatusUnauthorized,
+				cause:      "INVALID_TOKEN",
+			},
+		},
+		{
+			name: "Insufficient Scope",
+			args: Args{
+				token: Insufficient,
+			},
+			want: Want{
+				statusCode: http.StatusForbidden,
+				cause:      "INSUFFICIENT_SCOPE",
 			},
 		},
 	}
@@ -87,6 +106,15 @@ func TestRouterAuthorizationCheck_Check(t *testing.T) {
 			if w.Code != tt.want.statusCode {
 				t.Errorf("StatusCode should be %d, but got %d", tt.want.statusCode, w.Code)
 			}
+			if tt.want.cause != "" {
+				var problemDetails models.ProblemDetails
+				if err = json.Unmarshal(w.Body.Bytes(), &problemDetails); err != nil {
+					t.Errorf("error on problem details: %+v", err)
+				}
+				if problemDetails.Cause != tt.want.cause {
+					t.Errorf("Cause should be %s, but got %s", tt.want.cause, problemDetails.Cause)
+				}
+			}
 		})
 	}
 }
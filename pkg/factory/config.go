@@ -6,10 +6,14 @@ package factory
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/adjivas/eir/internal/logger"
 	"github.com/asaskevich/govalidator"
+	"github.com/free5gc/openapi/models"
 )
 
 const (
@@ -22,8 +26,39 @@ const (
 	EirSbiDefaultScheme      = "https"
 	EirDefaultNrfUri         = "https://127.0.0.10:8000"
 	EirDrResUriPrefix        = "/n5g-eir-eic/v1"
+	EirProvResUriPrefix      = "/n5g-eir-prov/v1"
+	// EirCallbackResUriPrefix is the prefix of the notifications sent to the EIR
+	EirCallbackResUriPrefix = "/n5g-eir-callback/v1"
+	// EirProvServiceName is the OAuth2 scope of the provisioning routes
+	EirProvServiceName = "n5g-eir-prov"
 )
 
+const (
+	EquipmentStatusWhitelisted = "WHITELISTED"
+	EquipmentStatusBlacklisted = "BLACKLISTED"
+	EquipmentStatusGreylisted  = "GREYLISTED"
+)
+
+// EquipmentStatuses lists every status accepted by the configuration and the provisioning API
+var EquipmentStatuses = []string{
+	EquipmentStatusWhitelisted,
+	EquipmentStatusBlacklisted,
+	EquipmentStatusGreylisted,
+}
+
+func init() {
+	govalidator.TagMap["equipmentstatus"] = govalidator.Validator(IsValidEquipmentStatus)
+}
+
+func IsValidEquipmentStatus(status string) bool {
+	for _, equipmentStatus := range EquipmentStatuses {
+		if status == equipmentStatus {
+			return true
+		}
+	}
+	return false
+}
+
 type DbType string
 
 type Config struct {
@@ -63,22 +98,383 @@ const (
 
 type Configuration struct {
 	Sbi             *Sbi     `yaml:"sbi" valid:"required"`
-	DefaultStatus   string   `yaml:"defaultStatus" valid:"in(WHITELISTED|BLACKLISTED),optional"`
-	DbConnectorType DbType   `yaml:"dbConnectorType" valid:"required,in(mongodb)"`
+	DefaultStatus   string   `yaml:"defaultStatus" valid:"equipmentstatus,optional"`
+	DbConnectorType DbType   `yaml:"dbConnectorType" valid:"required,in(mongodb|memory|sql)"`
 	Mongodb         *Mongodb `yaml:"mongodb" valid:"optional"`
-	NrfUri          string   `yaml:"nrfUri" valid:"url,required"`
+	Memory          *Memory  `yaml:"memory" valid:"optional"`
+	Sql             *Sql     `yaml:"sql" valid:"optional"`
+	NrfUri          string   `yaml:"nrfUri,omitempty" valid:"url,optional"` // the first of the Nrfs by default
 	NrfCertPem      string   `yaml:"nrfCertPem,omitempty" valid:"optional"`
+	// Nrfs are tried in order when a NRF is unreachable, the NrfUri is the only one by default
+	Nrfs []*Nrf `yaml:"nrfs,omitempty" valid:"optional"`
+	// PeiLookupStrategy defaults to imei-fallback
+	PeiLookupStrategy string `yaml:"peiLookupStrategy,omitempty" valid:"in(exact|imei-fallback),optional"`
+	// UnallocatedTacStatus is returned for a IMEI/IMEISV without record nor rule whose TAC isn't in the TAC database
+	UnallocatedTacStatus string `yaml:"unallocatedTacStatus,omitempty" valid:"equipmentstatus,optional"`
+	// GreylistPolicies are tried in order, the first one matching the reason of a grey-listed record applies
+	GreylistPolicies []*GreylistPolicy `yaml:"greylistPolicies,omitempty" valid:"optional"`
+	CloneDetection   *CloneDetection   `yaml:"cloneDetection,omitempty" valid:"optional"`
+	// OAuth2 verifies the access tokens locally, the authorization is then always checked
+	OAuth2          *OAuth2          `yaml:"oauth2,omitempty" valid:"optional"`
+	NrfRegistration *NrfRegistration `yaml:"nrfRegistration,omitempty" valid:"optional"`
+	// PlmnList, SNssais, Locality, Capacity, Priority and Fqdn are registered in the NF profile for the NRF selection
+	PlmnList []models.PlmnId    `yaml:"plmnList,omitempty" valid:"optional"`
+	SNssais  []models.ExtSnssai `yaml:"sNssais,omitempty" valid:"optional"`
+	Locality string             `yaml:"locality,omitempty" valid:"type(string),optional"`
+	Capacity int                `yaml:"capacity,omitempty" valid:"range(0|65535),optional"`
+	Priority int                `yaml:"priority,omitempty" valid:"range(0|65535),optional"`
+	Fqdn     string             `yaml:"fqdn,omitempty" valid:"dns,optional"`
+	// Metrics serves the Prometheus metrics on a listener separated from the SBI, they aren't served without it
+	Metrics *Metrics `yaml:"metrics,omitempty" valid:"optional"`
+	// Probes serves the liveness and the readiness probes in plain HTTP on a listener separated from the SBI, the
+	// orchestrator reaches them without the client certificate required by the SBI
+	Probes *Probes `yaml:"probes,omitempty" valid:"optional"`
+	// Tracing exports the OpenTelemetry spans, they aren't exported without it
+	Tracing *Tracing `yaml:"tracing,omitempty" valid:"optional"`
+	// Audit writes the hash-chained records of the equipment identity checks and of the provisioning
+	Audit *Audit `yaml:"audit,omitempty" valid:"optional"`
 }
 
 func (c *Configuration) validate() (bool, error) {
+	for _, policy := range c.GreylistPolicies {
+		if result, err := policy.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if cloneDetection := c.CloneDetection; cloneDetection != nil {
+		if result, err := cloneDetection.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	var errs govalidator.Errors
+	if c.NrfUri == "" && len(c.Nrfs) == 0 {
+		errs = append(errs, fmt.Errorf("Configuration.NrfUri or Configuration.Nrfs is required"))
+	}
+	for _, nrf := range c.Nrfs {
+		if nrf == nil {
+			errs = append(errs, fmt.Errorf("Configuration.Nrfs has an empty NRF"))
+			continue
+		}
+		if _, err := govalidator.ValidateStruct(nrf); err != nil {
+			errs = append(errs, err.(govalidator.Errors).Errors()...)
+		}
+	}
+	for _, plmnId := range c.PlmnList {
+		if !mccPattern.MatchString(plmnId.Mcc) || !mncPattern.MatchString(plmnId.Mnc) {
+			errs = append(errs, fmt.Errorf("Configuration.PlmnList has a malformed PLMN [%s-%s]", plmnId.Mcc, plmnId.Mnc))
+		}
+	}
+	for _, snssai := range c.SNssais {
+		if snssai.Sst < 0 || snssai.Sst > 255 || (snssai.Sd != "" && !sdPattern.MatchString(snssai.Sd)) {
+			errs = append(errs, fmt.Errorf("Configuration.SNssais has a malformed S-NSSAI [%d-%s]", snssai.Sst, snssai.Sd))
+		}
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
+	// Set a default Nrfs/NrfUri if the Configuration does not provides them
+	if len(c.Nrfs) == 0 {
+		c.Nrfs = []*Nrf{{Uri: c.NrfUri}}
+	} else if c.NrfUri == "" {
+		c.NrfUri = c.Nrfs[0].Uri
+	}
+	for _, nrf := range c.Nrfs {
+		if nrf.CertPem == "" {
+			nrf.CertPem = c.NrfCertPem
+		}
+	}
+
+	// Set a default NrfRegistration if the Configuration does not provides one
+	if c.NrfRegistration == nil {
+		c.NrfRegistration = &NrfRegistration{}
+	}
+	if result, err := c.NrfRegistration.validate(); err != nil {
+		return result, err
+	}
+
+	if oauth2 := c.OAuth2; oauth2 != nil {
+		if result, err := oauth2.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if metrics := c.Metrics; metrics != nil {
+		if result, err := metrics.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if probes := c.Probes; probes != nil {
+		if result, err := probes.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if tracing := c.Tracing; tracing != nil {
+		if result, err := tracing.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if audit := c.Audit; audit != nil {
+		if result, err := audit.validate(); err != nil {
+			return result, err
+		}
+	}
+
 	if sbi := c.Sbi; sbi != nil {
-		return sbi.validate()
+		if result, err := sbi.validate(); err != nil {
+			return result, err
+		}
 	}
 
 	result, err := govalidator.ValidateStruct(c)
 	return result, appendInvalid(err)
 }
 
+var (
+	mccPattern = regexp.MustCompile(`^[0-9]{3}$`)
+	mncPattern = regexp.MustCompile(`^[0-9]{2,3}$`)
+	sdPattern  = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+)
+
+const (
+	// PeiLookupExact only looks up the PEI as it's received
+	PeiLookupExact = "exact"
+	// PeiLookupImeiFallback looks up the IMEI of an IMEISV when the IMEISV itself isn't found
+	PeiLookupImeiFallback = "imei-fallback"
+)
+
+const (
+	GreylistActionLog      = "log"
+	GreylistActionNotify   = "notify"
+	GreylistActionEscalate = "escalate"
+)
+
+type GreylistPolicy struct {
+	Reason          string `yaml:"reason,omitempty" valid:"type(string),optional"` // empty matches every reason
+	Action          string `yaml:"action" valid:"required,in(log|notify|escalate)"`
+	NotificationUri string `yaml:"notificationUri,omitempty" valid:"url,optional"`
+	EscalateAfter   int    `yaml:"escalateAfter,omitempty" valid:"optional"` // lookups before the blacklisting
+}
+
+func (g *GreylistPolicy) validate() (bool, error) {
+	var errs govalidator.Errors
+
+	if g.Action == GreylistActionNotify && g.NotificationUri == "" {
+		errs = append(errs, fmt.Errorf("GreylistPolicy.NotificationUri is required by the notify action"))
+	}
+	if g.Action == GreylistActionEscalate && g.EscalateAfter < 1 {
+		errs = append(errs, fmt.Errorf("GreylistPolicy.EscalateAfter must be positive with the escalate action"))
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(g)
+	return result, appendInvalid(err)
+}
+
+// CloneDetectionReason is the reason of the PEIs grey-listed by the clone detection
+const CloneDetectionReason = "cloned"
+
+// CloneDetection flags a PEI as cloned when it's paired with more than MaxSupis SUPIs during the Window
+type CloneDetection struct {
+	Window   time.Duration `yaml:"window" valid:"required"`
+	MaxSupis int           `yaml:"maxSupis,omitempty" valid:"optional"` // defaults to 1
+	// AutoGreylist returns GREYLISTED instead of WHITELISTED for a cloned PEI, with the cloned reason
+	AutoGreylist bool `yaml:"autoGreylist,omitempty" valid:"type(bool),optional"`
+}
+
+func (c *CloneDetection) validate() (bool, error) {
+	// Set a default MaxSupis if the Configuration does not provides one
+	if c.MaxSupis == 0 {
+		c.MaxSupis = 1
+	}
+
+	var errs govalidator.Errors
+	if c.Window < 0 {
+		errs = append(errs, fmt.Errorf("CloneDetection.Window must be positive"))
+	}
+	if c.MaxSupis < 0 {
+		errs = append(errs, fmt.Errorf("CloneDetection.MaxSupis must be positive"))
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(c)
+	return result, appendInvalid(err)
+}
+
+// Nrf is a NRF endpoint with its certificate
+type Nrf struct {
+	Uri     string `yaml:"uri" valid:"url,required"`
+	CertPem string `yaml:"certPem,omitempty" valid:"type(string),optional"` // defaults to the NrfCertPem
+}
+
+const (
+	NrfRegistrationDefaultInitialBackoff = time.Second
+	NrfRegistrationDefaultMaxBackoff     = time.Minute
+	// NrfUnreachableServe serves the requests while the EIR isn't registered to the NRF
+	NrfUnreachableServe = "serve"
+	// NrfUnreachableRefuse refuses the requests while the EIR isn't registered to the NRF
+	NrfUnreachableRefuse = "refuse"
+)
+
+const MetricsDefaultPath = "/metrics"
+
+// Metrics is the listener of the Prometheus metrics
+type Metrics struct {
+	BindingIP string `yaml:"bindingIP" valid:"ip,required"` // an IP, the metrics listener doesn't resolve hostnames
+	Port      int    `yaml:"port" valid:"port,required"`
+	Path      string `yaml:"path,omitempty" valid:"type(string),optional"` // defaults to /metrics
+}
+
+func (m *Metrics) validate() (bool, error) {
+	// Set a default Path if the Configuration does not provides one
+	if m.Path == "" {
+		m.Path = MetricsDefaultPath
+	}
+	if !strings.HasPrefix(m.Path, "/") {
+		return false, appendInvalid(govalidator.Errors{fmt.Errorf("Metrics.Path must start with /")})
+	}
+
+	result, err := govalidator.ValidateStruct(m)
+	return result, appendInvalid(err)
+}
+
+// Probes is the plain HTTP listener of the liveness and the readiness probes
+type Probes struct {
+	BindingIP string `yaml:"bindingIP" valid:"ip,required"`
+	Port      int    `yaml:"port" valid:"port,required"`
+}
+
+func (p *Probes) validate() (bool, error) {
+	result, err := govalidator.ValidateStruct(p)
+	return result, appendInvalid(err)
+}
+
+const (
+	TracingExporterStdout   = "stdout"
+	TracingExporterOtlpFile = "otlp-file"
+)
+
+// Tracing is the exporter of the OpenTelemetry spans
+type Tracing struct {
+	Exporter string `yaml:"exporter" valid:"in(stdout|otlp-file),required"`
+	Path     string `yaml:"path,omitempty" valid:"type(string),optional"` // the file of the otlp-file exporter
+}
+
+func (t *Tracing) validate() (bool, error) {
+	if t.Exporter == TracingExporterOtlpFile && t.Path == "" {
+		return false, appendInvalid(govalidator.Errors{fmt.Errorf("Tracing.Path is required by the otlp-file exporter")})
+	}
+
+	result, err := govalidator.ValidateStruct(t)
+	return result, appendInvalid(err)
+}
+
+const (
+	AuditSinkFile     = "file"
+	AuditSinkDatabase = "database"
+	// AuditDefaultMaxSize is the size of the audit file rotating it, 10 MiB
+	AuditDefaultMaxSize = 10 << 20
+)
+
+// Audit is the sink of the audit records
+type Audit struct {
+	Sink    string `yaml:"sink" valid:"in(file|database),required"`
+	Path    string `yaml:"path,omitempty" valid:"type(string),optional"` // the file of the file sink
+	MaxSize int64  `yaml:"maxSize,omitempty" valid:"optional"`           // the size in bytes rotating the file
+}
+
+func (a *Audit) validate() (bool, error) {
+	// Set a default MaxSize if the Configuration does not provides one
+	if a.MaxSize == 0 {
+		a.MaxSize = AuditDefaultMaxSize
+	}
+
+	var errs govalidator.Errors
+	if a.Sink == AuditSinkFile && a.Path == "" {
+		errs = append(errs, fmt.Errorf("Audit.Path is required by the file sink"))
+	}
+	if a.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("Audit.MaxSize must be positive"))
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(a)
+	return result, appendInvalid(err)
+}
+
+// NrfRegistration retries the registration to the NRF in the background with an exponential backoff
+type NrfRegistration struct {
+	InitialBackoff time.Duration `yaml:"initialBackoff,omitempty" valid:"optional"` // defaults to 1s
+	MaxBackoff     time.Duration `yaml:"maxBackoff,omitempty" valid:"optional"`     // defaults to 1m
+	// Unreachable decides how the requests are served while the EIR isn't registered, it defaults to serve
+	Unreachable string `yaml:"unreachable,omitempty" valid:"in(serve|refuse),optional"`
+}
+
+func (n *NrfRegistration) validate() (bool, error) {
+	// Set a default InitialBackoff/MaxBackoff/Unreachable if the Configuration does not provides them
+	if n.InitialBackoff == 0 {
+		n.InitialBackoff = NrfRegistrationDefaultInitialBackoff
+	}
+	if n.MaxBackoff == 0 {
+		n.MaxBackoff = NrfRegistrationDefaultMaxBackoff
+	}
+	if n.Unreachable == "" {
+		n.Unreachable = NrfUnreachableServe
+	}
+
+	var errs govalidator.Errors
+	if n.InitialBackoff < 0 {
+		errs = append(errs, fmt.Errorf("NrfRegistration.InitialBackoff must be positive"))
+	}
+	if n.MaxBackoff < n.InitialBackoff {
+		errs = append(errs, fmt.Errorf("NrfRegistration.MaxBackoff must be greater than NrfRegistration.InitialBackoff"))
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(n)
+	return result, appendInvalid(err)
+}
+
+// OAuth2 verifies the access tokens against the JSON Web Key Set of a file or of the configuration
+type OAuth2 struct {
+	JwksPath string `yaml:"jwksPath,omitempty" valid:"type(string),optional"` // reloaded when it's modified
+	Jwks     string `yaml:"jwks,omitempty" valid:"type(string),optional"`     // inline JSON Web Key Set
+	// Audience defaults to the 5G_EIR NF type and the NF instance ID of the EIR
+	Audience []string `yaml:"audience,omitempty" valid:"optional"`
+	// AllowedNfTypes are the NF types of the consumers of the equipment identity check, it defaults to AMF
+	AllowedNfTypes []string `yaml:"allowedNfTypes,omitempty" valid:"optional"`
+	// ProvisioningNfTypes are the NF types of the consumers of the provisioning, without them any NF type granted
+	// the provisioning scope is allowed
+	ProvisioningNfTypes []string `yaml:"provisioningNfTypes,omitempty" valid:"optional"`
+}
+
+func (o *OAuth2) validate() (bool, error) {
+	// Set a default AllowedNfTypes if the Configuration does not provides one
+	if len(o.AllowedNfTypes) == 0 {
+		o.AllowedNfTypes = []string{"AMF"}
+	}
+
+	if (o.JwksPath == "") == (o.Jwks == "") {
+		return false, appendInvalid(govalidator.Errors{fmt.Errorf("OAuth2 requires either a JwksPath or a Jwks")})
+	}
+
+	result, err := govalidator.ValidateStruct(o)
+	return result, appendInvalid(err)
+}
+
 type Sbi struct {
 	Scheme     string `yaml:"scheme" valid:"in(http|https),optional"`
 	RegisterIP string `yaml:"registerIP,omitempty" valid:"host,optional"` // IP that is registered at NRF.
@@ -124,18 +520,71 @@ func (s *Sbi) validate() (bool, error) {
 type Tls struct {
 	Pem string `yaml:"pem,omitempty" valid:"type(string),minstringlength(1),required"`
 	Key string `yaml:"key,omitempty" valid:"type(string),minstringlength(1),required"`
+	// ClientCa is the CA bundle verifying the client certificates
+	ClientCa string `yaml:"clientCa,omitempty" valid:"type(string),optional"`
+	// RequireClientCert refuses the handshakes without a valid client certificate
+	RequireClientCert bool `yaml:"requireClientCert,omitempty" valid:"type(bool),optional"`
+	// ClientAllowList maps the client identities to their routes, every client is allowed when it's empty
+	ClientAllowList []*ClientIdentity `yaml:"clientAllowList,omitempty" valid:"optional"`
+	// AllowAmfPeers allows the AMF peers discovered from the NRF to call the equipment-status routes
+	AllowAmfPeers bool `yaml:"allowAmfPeers,omitempty" valid:"type(bool),optional"`
 }
 
 func (t *Tls) validate() (bool, error) {
+	var errs govalidator.Errors
+
+	if t.ClientCa == "" && t.RequireClientCert {
+		errs = append(errs, fmt.Errorf("Tls.ClientCa is required by Tls.RequireClientCert"))
+	}
+	if t.ClientCa == "" && len(t.ClientAllowList) > 0 {
+		errs = append(errs, fmt.Errorf("Tls.ClientCa is required by Tls.ClientAllowList"))
+	}
+	if t.ClientCa == "" && t.AllowAmfPeers {
+		errs = append(errs, fmt.Errorf("Tls.ClientCa is required by Tls.AllowAmfPeers"))
+	}
+	for _, client := range t.ClientAllowList {
+		if _, err := govalidator.ValidateStruct(client); err != nil {
+			errs = append(errs, err.(govalidator.Errors).Errors()...)
+		}
+	}
+	if len(errs) > 0 {
+		return false, appendInvalid(errs)
+	}
+
 	result, err := govalidator.ValidateStruct(t)
 	return result, err
 }
 
+// AllRoutes allows a client identity to call every route
+const AllRoutes = "*"
+
+// ClientIdentity allows the client certificates of an identity to call some routes
+type ClientIdentity struct {
+	// Identity is matched against the DNS and URI SANs, the NF instance ID of a urn:uuid URI SAN, and the CN
+	Identity string `yaml:"identity" valid:"type(string),minstringlength(1),required"`
+	// Routes are the names of the routes allowed, as EquipmentStatus or CreateEquipmentStatus
+	Routes []string `yaml:"routes" valid:"required"`
+}
+
 type Mongodb struct {
 	Name string `yaml:"name" valid:"type(string),required"`
 	Url  string `yaml:"url" valid:"required,required"`
 }
 
+type Memory struct {
+	Seed string `yaml:"seed,omitempty" valid:"type(string),optional"` // YAML or JSON documents by collection
+}
+
+const (
+	SqlDriverSqlite   = "sqlite"
+	SqlDriverPostgres = "postgres"
+)
+
+type Sql struct {
+	Driver string `yaml:"driver" valid:"required,in(sqlite|postgres)"`
+	Dsn    string `yaml:"dsn" valid:"type(string),required"` // Data source name of the driver
+}
+
 func appendInvalid(err error) error {
 	var errs govalidator.Errors
 
@@ -235,6 +684,30 @@ func (c *Config) GetLogReportCaller() bool {
 	return c.Logger.ReportCaller
 }
 
+func (c *Config) SetDefaultStatus(defaultStatus string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Configuration.DefaultStatus = defaultStatus
+}
+
+func (c *Config) GetDefaultStatus() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Configuration.DefaultStatus
+}
+
+func (c *Config) SetOAuth2(oauth2 *OAuth2) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Configuration.OAuth2 = oauth2
+}
+
+func (c *Config) GetOAuth2() *OAuth2 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Configuration.OAuth2
+}
+
 func (c *Config) GetCertPemPath() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -31,11 +31,20 @@ func InitConfigFactory(f string, cfg *Config) error {
 	return nil
 }
 
-func ReadConfig(cfgPath string) (*Config, error) {
+// ReadConfig reads the configuration file, overrides it with the EIR_* environment variables then with the
+// key.path=value overrides of the flags, and validates it
+func ReadConfig(cfgPath string, overrides ...string) (*Config, error) {
 	cfg := &Config{}
 	if err := InitConfigFactory(cfgPath, cfg); err != nil {
 		return nil, fmt.Errorf("ReadConfig [%s] Error: %+v", cfgPath, err)
 	}
+	cfg.applyLegacyEnv()
+	if err := cfg.ApplyEnv(os.Environ()); err != nil {
+		return nil, fmt.Errorf("ReadConfig [%s] Error: %+v", cfgPath, err)
+	}
+	if err := cfg.ApplyOverrides(overrides); err != nil {
+		return nil, fmt.Errorf("ReadConfig [%s] Error: %+v", cfgPath, err)
+	}
 	if _, err := cfg.Validate(); err != nil {
 		validErrs := err.(govalidator.Errors).Errors()
 		for _, validErr := range validErrs {
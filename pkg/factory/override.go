@@ -0,0 +1,228 @@
+package factory
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/adjivas/eir/internal/logger"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix prefixes the environment variables overriding the configuration, as EIR_SBI_PORT or EIR_LOGGER_LEVEL.
+// The keys of the configuration section are also reached without their configuration prefix.
+const EnvPrefix = "EIR_"
+
+const redacted = "REDACTED"
+
+// dsnSecret matches the secrets of the key/value data source names, as password=eir or password='e i r' whose
+// quotes may escape a quote with a backslash
+var dsnSecret = regexp.MustCompile(`(?i)\b(password|pwd|secret|token)=('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|[^\s&;]+)`)
+
+// yamlKey returns the YAML key of the struct field, yaml.v2 lowercases the field name by default
+func yamlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// fieldByKey returns the index of the field whose YAML key matches the key, the case is ignored
+func fieldByKey(t reflect.Type, key string) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && yamlKey(field) != "-" && strings.EqualFold(yamlKey(field), key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// checkPath reports whether the keys reach a field of the type, the slice elements are reached by their index
+func checkPath(t reflect.Type, keys []string) error {
+	for _, key := range keys {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			i, ok := fieldByKey(t, key)
+			if !ok {
+				return fmt.Errorf("the key [%s] is unknown", key)
+			}
+			t = t.Field(i).Type
+		case reflect.Slice:
+			if index, err := strconv.Atoi(key); err != nil || index < 0 {
+				return fmt.Errorf("the key [%s] isn't an index", key)
+			}
+			t = t.Elem()
+		default:
+			return fmt.Errorf("the key [%s] is under a value", key)
+		}
+	}
+	return nil
+}
+
+// setField decodes the value into the field reached by the keys, the missing structs and elements are created.
+// The path must be checked before.
+func setField(v reflect.Value, keys []string, value string) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(keys) == 0 {
+		// The strings are taken as they're, the other values are decoded as YAML
+		if v.Kind() == reflect.String {
+			v.SetString(value)
+			return nil
+		}
+		decoded := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(value), decoded.Interface()); err != nil {
+			return err
+		}
+		v.Set(decoded.Elem())
+		return nil
+	}
+
+	if v.Kind() == reflect.Slice {
+		index, _ := strconv.Atoi(keys[0])
+		if index >= v.Len() {
+			grown := reflect.MakeSlice(v.Type(), index+1, index+1)
+			// The skipped elements are left empty for the validation to report them
+			for i := reflect.Copy(grown, v); i < grown.Len(); i++ {
+				if elem := grown.Index(i); elem.Kind() == reflect.Pointer {
+					elem.Set(reflect.New(elem.Type().Elem()))
+				}
+			}
+			v.Set(grown)
+		}
+		return setField(v.Index(index), keys[1:], value)
+	}
+	i, _ := fieldByKey(v.Type(), keys[0])
+	return setField(v.Field(i), keys[1:], value)
+}
+
+// resolve returns the path of the field of the keys, the configuration prefix may be omitted
+func (c *Config) resolve(keys []string) ([]string, error) {
+	t := reflect.TypeOf(c)
+	err := checkPath(t, keys)
+	if err != nil {
+		configurationKeys := append([]string{"configuration"}, keys...)
+		if checkPath(t, configurationKeys) != nil {
+			return nil, err
+		}
+		return configurationKeys, nil
+	}
+	return keys, nil
+}
+
+// applyLegacyEnv replaces the SBI IPs naming an environment variable by its value
+func (c *Config) applyLegacyEnv() {
+	if c.Configuration == nil || c.Configuration.Sbi == nil {
+		return
+	}
+	sbi := c.Configuration.Sbi
+	if bindingIP := os.Getenv(sbi.BindingIP); sbi.BindingIP != "" && bindingIP != "" {
+		logger.CfgLog.Warnf("The BindingIP is read from [%s], prefer %sSBI_BINDINGIP", sbi.BindingIP, EnvPrefix)
+		sbi.BindingIP = bindingIP
+	}
+	if registerIP := os.Getenv(sbi.RegisterIP); sbi.RegisterIP != "" && registerIP != "" {
+		logger.CfgLog.Warnf("The RegisterIP is read from [%s], prefer %sSBI_REGISTERIP", sbi.RegisterIP, EnvPrefix)
+		sbi.RegisterIP = registerIP
+	}
+}
+
+// ApplyEnv overrides the configuration with the EIR_* variables of the environment. The variables which don't name
+// a field are ignored, as the ones injected by Kubernetes for a service.
+func (c *Config) ApplyEnv(environ []string) error {
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		path, found := strings.CutPrefix(name, EnvPrefix)
+		if !found || path == "" {
+			continue
+		}
+		keys, err := c.resolve(strings.Split(path, "_"))
+		if err != nil {
+			logger.CfgLog.Debugf("The %s variable isn't a configuration field: %+v", name, err)
+			continue
+		}
+		if err = setField(reflect.ValueOf(c), keys, value); err != nil {
+			return fmt.Errorf("the %s variable is invalid: %+v", name, err)
+		}
+		logger.CfgLog.Infof("The configuration is overridden by the %s variable", name)
+	}
+	return nil
+}
+
+// ApplyOverrides overrides the configuration with the key.path=value of the flags
+func (c *Config) ApplyOverrides(overrides []string) error {
+	for _, override := range overrides {
+		path, value, found := strings.Cut(override, "=")
+		if !found || path == "" {
+			return fmt.Errorf("the override [%s] isn't a key.path=value", override)
+		}
+		keys, err := c.resolve(strings.Split(path, "."))
+		if err == nil {
+			err = setField(reflect.ValueOf(c), keys, value)
+		}
+		if err != nil {
+			return fmt.Errorf("the override [%s] is invalid: %+v", override, err)
+		}
+	}
+	return nil
+}
+
+// redactSecret hides the password of an URL and the secrets of a key/value data source name
+func redactSecret(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+	}
+	return dsnSecret.ReplaceAllString(value, "${1}="+redacted)
+}
+
+func redactValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			redactValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				redactValue(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redactValue(v.Index(i))
+		}
+	case reflect.String:
+		v.SetString(redactSecret(v.String()))
+	}
+}
+
+// Redacted returns the YAML of the configuration whose secrets are hidden
+func (c *Config) Redacted() ([]byte, error) {
+	c.mu.RLock()
+	content, err := yaml.Marshal(c)
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	copied := &Config{}
+	if err = yaml.Unmarshal(content, copied); err != nil {
+		return nil, err
+	}
+	redactValue(reflect.ValueOf(copied))
+	return yaml.Marshal(copied)
+}
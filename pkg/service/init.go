@@ -4,20 +4,29 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/database"
+	"github.com/adjivas/eir/internal/database/mongodb"
 	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/internal/metrics"
 	"github.com/adjivas/eir/internal/sbi"
 	"github.com/adjivas/eir/internal/sbi/consumer"
 	"github.com/adjivas/eir/internal/sbi/processor"
+	"github.com/adjivas/eir/internal/tracing"
 	"github.com/adjivas/eir/pkg/app"
 	"github.com/adjivas/eir/pkg/factory"
 	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/openapi/nrf/NFDiscovery"
 	"github.com/free5gc/openapi/nrf/NFManagement"
 	"github.com/free5gc/util/mongoapi"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
@@ -28,10 +37,17 @@ type EirApp struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	wg        sync.WaitGroup
+	wg sync.WaitGroup
+	// nrfCancel stops the registration and the heartbeats to the NRF, nrfWg waits for them
+	nrfCancel context.CancelFunc
+	nrfWg     sync.WaitGroup
 	sbiServer *sbi.Server
 	processor *processor.Processor
 	consumer  *consumer.Consumer
+	// metricsServer is nil when the metrics aren't configured
+	metricsServer *metrics.Server
+	// shutdownTracing flushes the spans
+	shutdownTracing func(context.Context) error
 }
 
 var _ app.App = &EirApp{}
@@ -50,6 +66,12 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Ei
 	eir.SetLogLevel(cfg.GetLogLevel())
 	eir.SetReportCaller(cfg.GetLogReportCaller())
 
+	shutdownTracing, err := tracing.Init(cfg.Configuration.Tracing, cfg.GetVersion())
+	if err != nil {
+		return nil, err
+	}
+	eir.shutdownTracing = shutdownTracing
+
 	processor := processor.NewProcessor(eir)
 	eir.processor = processor
 
@@ -58,6 +80,14 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Ei
 
 	eir.sbiServer = sbi.NewServer(eir, tlsKeyLogPath)
 
+	if metricsConfig := cfg.Configuration.Metrics; metricsConfig != nil {
+		metricsServer, err := metrics.NewServer(metricsConfig)
+		if err != nil {
+			return nil, err
+		}
+		eir.metricsServer = metricsServer
+	}
+
 	return eir, nil
 }
 
@@ -118,16 +148,127 @@ func (a *EirApp) SetReportCaller(reportCaller bool) {
 func (u *EirApp) registerToNrf(ctx context.Context) error {
 	eirContext := u.eirCtx
 
-	nrfUri, nfId, err := u.consumer.SendRegisterNFInstance(ctx, eirContext.NrfUri)
+	nrfUri, nfId, err := u.consumer.SendRegisterNFInstance(ctx, eirContext.NrfUri())
 	if err != nil {
 		return fmt.Errorf("send register NFInstance error[%s]", err.Error())
 	}
-	eirContext.NrfUri = nrfUri
-	eirContext.NfId = nfId
+	eirContext.UseNrf(nrfUri)
+	eirContext.SetNfId(nfId)
+	eirContext.SetRegistered(true)
+	// The subscription of a previous registration is replaced
+	u.unfollowAmfPeers()
+	u.followAmfPeers()
 
 	return nil
 }
 
+// followAmfPeers discovers the AMF instances and subscribes to their status to keep the AMF peers up to date
+func (a *EirApp) followAmfPeers() {
+	targetNfType := models.NrfNfManagementNfType_AMF
+	requesterNfType := models.NrfNfManagementNfType__5_G_EIR
+	res, err := a.consumer.SendSearchNFInstances(a.eirCtx.NrfUri(), NFDiscovery.SearchNFInstancesRequest{
+		TargetNfType:    &targetNfType,
+		RequesterNfType: &requesterNfType,
+	})
+	if err != nil {
+		logger.InitLog.Errorf("The AMF instances can't be discovered: %v", err)
+	} else if res != nil {
+		peers := make([]eir_context.AmfPeer, 0, len(res.SearchResult.NfInstances))
+		for i := range res.SearchResult.NfInstances {
+			peers = append(peers, eir_context.AmfPeerFromDiscovery(&res.SearchResult.NfInstances[i]))
+		}
+		a.eirCtx.ReplaceAmfPeers(peers)
+		logger.InitLog.Infof("%d AMF instances are discovered", len(peers))
+	}
+
+	notificationId := uuid.New().String()
+	a.eirCtx.SetAmfNotificationId(notificationId)
+	subscriptionId, validityTime, err := a.consumer.SendCreateSubscription(a.eirCtx.NrfUri(), notificationId)
+	if err != nil {
+		logger.InitLog.Errorf("The subscription to the AMF status failed: %v", err)
+		return
+	}
+	a.eirCtx.SetAmfSubscription(subscriptionId, validityTime)
+	logger.InitLog.Infof("Subscribed to the AMF status [%s]", subscriptionId)
+}
+
+// unfollowAmfPeers removes the subscription to the AMF status
+func (a *EirApp) unfollowAmfPeers() {
+	subscriptionId, _ := a.eirCtx.AmfSubscription()
+	if subscriptionId == "" {
+		return
+	}
+	if err := a.consumer.SendRemoveSubscription(subscriptionId); err != nil {
+		logger.InitLog.Errorf("The subscription to the AMF status can't be removed: %v", err)
+	}
+	a.eirCtx.SetAmfSubscription("", time.Time{})
+	a.eirCtx.SetAmfNotificationId("")
+}
+
+// renewAmfPeers subscribes again to the AMF status when the subscription expires within the next two heartbeats,
+// it's only called by the NRF goroutine, which is stopped before the subscription is removed on termination
+func (a *EirApp) renewAmfPeers(heartBeatTimer time.Duration) {
+	subscriptionId, validity := a.eirCtx.AmfSubscription()
+	if validity.IsZero() || time.Until(validity) > 2*heartBeatTimer {
+		return
+	}
+	logger.InitLog.Infof("The subscription to the AMF status [%s] expires at %s, it's renewed",
+		subscriptionId, validity.Format(time.RFC3339))
+	a.unfollowAmfPeers()
+	a.followAmfPeers()
+}
+
+// keepRegisteredToNrf registers the EIR to the NRF with an exponential backoff, then sends its heartbeats
+func (a *EirApp) keepRegisteredToNrf(ctx context.Context) {
+	defer a.nrfWg.Done()
+
+	if err := a.registerToNrf(ctx); err != nil {
+		logger.InitLog.Errorf("register to NRF failed: %v", err)
+		return
+	}
+	logger.InitLog.Infof("register to NRF successfully")
+	a.heartbeatToNrf(ctx)
+}
+
+// heartbeatToNrf sends the NF heartbeats at the heartbeat timer of the NRF until the context is done,
+// the EIR is registered again when the NRF doesn't know it anymore
+func (a *EirApp) heartbeatToNrf(ctx context.Context) {
+	heartBeatTimer := func() time.Duration {
+		if heartBeatTimer := a.eirCtx.HeartBeatTimer(); heartBeatTimer > 0 {
+			return heartBeatTimer
+		}
+		return consumer.DEFAULT_HEARTBEAT_TIMER
+	}
+	timer := time.NewTimer(heartBeatTimer())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.InitLog.Infof("The NRF heartbeat is stopped")
+			return
+		case <-timer.C:
+		}
+
+		profile, pd, err := a.consumer.SendUpdateNFInstance()
+		switch {
+		case pd != nil && pd.Status == http.StatusNotFound:
+			logger.InitLog.Warnf("The NRF doesn't know the EIR anymore, it's registered again")
+			a.eirCtx.SetRegistered(false)
+			if err = a.registerToNrf(ctx); err != nil {
+				logger.InitLog.Errorf("register to NRF failed: %v", err)
+			}
+		case err != nil:
+			logger.InitLog.Errorf("The NRF heartbeat failed: %v Problem[%+v]", err, pd)
+		case profile != nil && profile.HeartBeatTimer > 0:
+			a.eirCtx.SetHeartBeatTimer(time.Duration(profile.HeartBeatTimer) * time.Second)
+		}
+		if a.eirCtx.IsRegistered() {
+			a.renewAmfPeers(heartBeatTimer())
+		}
+		timer.Reset(heartBeatTimer())
+	}
+}
+
 func (a *EirApp) deregisterFromNrf() {
 	err := a.consumer.SendDeregisterNFInstance()
 	if err != nil {
@@ -152,20 +293,17 @@ func (a *EirApp) deregisterFromNrf() {
 func (a *EirApp) Start() {
 	// get config file info
 	config := factory.EirConfig
-	mongodb := config.Configuration.Mongodb
 
-	// Connect to MongoDB
-	if err := mongoapi.SetMongoDB(mongodb.Name, mongodb.Url); err != nil {
-		logger.InitLog.Errorf("EIR start set MongoDB error: %+v", err)
-		return
-	}
-
-	// Register to Nrf
-	err := a.registerToNrf(a.ctx)
-	if err != nil {
-		logger.InitLog.Errorf("register to NRF failed: %v", err)
-	} else {
-		logger.InitLog.Infof("register to NRF successfully")
+	// Connect to MongoDB, the memory database is ready since the processor creation
+	if config.Configuration.DbConnectorType == database.DBCONNECTOR_TYPE_MONGODB {
+		mongodbConfig := config.Configuration.Mongodb
+		if err := mongoapi.SetMongoDB(mongodbConfig.Name, mongodbConfig.Url); err != nil {
+			logger.InitLog.Errorf("EIR start set MongoDB error: %+v", err)
+			return
+		}
+		if err := mongodb.CreateIndexes(a.ctx, mongodbConfig.Name); err != nil {
+			logger.InitLog.Warnf("EIR start MongoDB indexes error: %+v", err)
+		}
 	}
 
 	// Graceful deregister when panic
@@ -178,10 +316,22 @@ func (a *EirApp) Start() {
 
 	logger.InitLog.Infoln("Server started")
 
+	// The NRF goroutine is counted before the shutdown can wait for it
+	var nrfCtx context.Context
+	nrfCtx, a.nrfCancel = context.WithCancel(a.ctx)
+	a.nrfWg.Add(1)
+
 	a.wg.Add(1)
 	go a.listenShutdown(a.ctx)
 
 	a.sbiServer.Run(&a.wg)
+	if a.metricsServer != nil {
+		a.metricsServer.Run(&a.wg)
+	}
+
+	// Register to Nrf in the background, the SBI is already served
+	go a.keepRegisteredToNrf(nrfCtx)
+
 	a.WaitRoutineStopped()
 }
 
@@ -199,13 +349,33 @@ func (a *EirApp) Terminate() {
 func (a *EirApp) terminateProcedure() {
 	logger.MainLog.Infof("Terminating EIR...")
 	a.CallServerStop()
-	a.deregisterFromNrf()
+	// The registration and the heartbeats are stopped first, a renewal can't subscribe again after the removal
+	a.stopNrfRoutine()
+	if a.eirCtx.IsRegistered() {
+		a.unfollowAmfPeers()
+		a.deregisterFromNrf()
+		a.eirCtx.SetRegistered(false)
+	}
+	if err := a.shutdownTracing(context.Background()); err != nil {
+		logger.MainLog.Errorf("The spans can't be flushed: %+v", err)
+	}
+}
+
+// stopNrfRoutine cancels the registration and the heartbeats to the NRF and waits for them
+func (a *EirApp) stopNrfRoutine() {
+	if a.nrfCancel != nil {
+		a.nrfCancel()
+	}
+	a.nrfWg.Wait()
 }
 
 func (a *EirApp) CallServerStop() {
 	if a.sbiServer != nil {
 		a.sbiServer.Shutdown()
 	}
+	if a.metricsServer != nil {
+		a.metricsServer.Shutdown()
+	}
 }
 
 func (a *EirApp) WaitRoutineStopped() {
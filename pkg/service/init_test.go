@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/sbi/consumer"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/free5gc/openapi/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNfId = "5c0d1a2e-7f00-4b8e-9b1c-000000000015"
+
+// fakeNrf answers the heartbeats with a heartbeat timer then with a 404, and records the requests
+type fakeNrf struct {
+	mu            sync.Mutex
+	patches       [][]models.PatchItem
+	patchedAt     []time.Time
+	registrations int
+	deregistered  int
+	// subscriptions are the notification URIs of the subscriptions created, removed are their removed IDs
+	subscriptions []string
+	removed       []string
+	subscribed    chan struct{}
+	validityTime  *time.Time
+}
+
+func newFakeNrf() *fakeNrf {
+	return &fakeNrf{subscribed: make(chan struct{}, 1)}
+}
+
+func (n *fakeNrf) handler(t *testing.T, uri func() string) http.Handler {
+	const instancesPath = "/nnrf-nfm/v1/nf-instances/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPatch && r.URL.Path == instancesPath+testNfId:
+			n.heartbeat(t, w, r)
+		case r.Method == http.MethodPut && r.URL.Path == instancesPath+testNfId:
+			n.register(t, w, uri())
+		case r.Method == http.MethodDelete && r.URL.Path == instancesPath+testNfId:
+			n.mu.Lock()
+			n.deregistered++
+			n.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/nnrf-disc/v1/nf-instances":
+			w.Header().Set("Content-Type", "application/json")
+			assert.Nil(t, json.NewEncoder(w).Encode(models.SearchResult{}))
+		case r.Method == http.MethodPost && r.URL.Path == "/nnrf-nfm/v1/subscriptions":
+			n.subscribe(t, w, r, uri())
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/nnrf-nfm/v1/subscriptions/"):
+			n.mu.Lock()
+			n.removed = append(n.removed, strings.TrimPrefix(r.URL.Path, "/nnrf-nfm/v1/subscriptions/"))
+			n.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("The NRF doesn't expect %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+}
+
+func (n *fakeNrf) heartbeat(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	var patch []models.PatchItem
+	assert.Nil(t, json.NewDecoder(r.Body).Decode(&patch))
+	n.mu.Lock()
+	n.patches = append(n.patches, patch)
+	n.patchedAt = append(n.patchedAt, time.Now())
+	count := len(n.patches)
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if count > 1 {
+		w.WriteHeader(http.StatusNotFound)
+		assert.Nil(t, json.NewEncoder(w).Encode(models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "RESOURCE_NOT_FOUND",
+		}))
+		return
+	}
+	assert.Nil(t, json.NewEncoder(w).Encode(models.NrfNfManagementNfProfile{
+		NfInstanceId:   testNfId,
+		NfType:         models.NrfNfManagementNfType__5_G_EIR,
+		NfStatus:       models.NrfNfManagementNfStatus_REGISTERED,
+		HeartBeatTimer: 1,
+	}))
+}
+
+func (n *fakeNrf) register(t *testing.T, w http.ResponseWriter, uri string) {
+	n.mu.Lock()
+	n.registrations++
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", uri+"/nnrf-nfm/v1/nf-instances/"+testNfId)
+	w.WriteHeader(http.StatusCreated)
+	assert.Nil(t, json.NewEncoder(w).Encode(models.NrfNfManagementNfProfile{
+		NfInstanceId:   testNfId,
+		NfType:         models.NrfNfManagementNfType__5_G_EIR,
+		NfStatus:       models.NrfNfManagementNfStatus_REGISTERED,
+		HeartBeatTimer: 2,
+	}))
+}
+
+func (n *fakeNrf) subscribe(t *testing.T, w http.ResponseWriter, r *http.Request, uri string) {
+	var subscription models.NrfNfManagementSubscriptionData
+	assert.Nil(t, json.NewDecoder(r.Body).Decode(&subscription))
+	n.mu.Lock()
+	n.subscriptions = append(n.subscriptions, subscription.NfStatusNotificationUri)
+	subscriptionId := fmt.Sprintf("amf-status-%d", len(n.subscriptions))
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", uri+"/nnrf-nfm/v1/subscriptions/"+subscriptionId)
+	w.WriteHeader(http.StatusCreated)
+	assert.Nil(t, json.NewEncoder(w).Encode(models.NrfNfManagementSubscriptionData{
+		SubscriptionId: subscriptionId,
+		ValidityTime:   n.validityTime,
+	}))
+	select {
+	case n.subscribed <- struct{}{}:
+	default:
+	}
+}
+
+// setupNrfApp returns the app of the EIR registered to the fake NRF
+func setupNrfApp(t *testing.T, nrf *fakeNrf) *EirApp {
+	var server *httptest.Server
+	server = httptest.NewServer(nrf.handler(t, func() string { return server.URL }))
+	t.Cleanup(server.Close)
+
+	eirSelf := eir_context.GetSelf()
+	nfId, nrfUri, nrfs, heartBeatTimer := eirSelf.NfId(), eirSelf.NrfUri(), eirSelf.Nrfs, eirSelf.HeartBeatTimer()
+	t.Cleanup(func() {
+		eirSelf.Nrfs = nrfs
+		eirSelf.SetNfId(nfId)
+		eirSelf.SetHeartBeatTimer(heartBeatTimer)
+		eirSelf.UseNrf(nrfUri)
+		eirSelf.SetAmfSubscription("", time.Time{})
+		eirSelf.SetAmfNotificationId("")
+		eirSelf.SetRegistered(false)
+	})
+	eirSelf.SetNfId(testNfId)
+	eirSelf.UseNrf(server.URL)
+	eirSelf.Nrfs = nil
+	eirSelf.SetOAuth2Required(false)
+
+	app := &EirApp{
+		cfg:    &factory.Config{Configuration: &factory.Configuration{}},
+		eirCtx: eirSelf,
+	}
+	app.consumer = consumer.NewConsumer(app)
+	return app
+}
+
+// assertNotificationUri checks the notification URI carries the notification ID of the subscription
+func assertNotificationUri(t *testing.T, eirSelf *eir_context.EIRContext, notificationUri string) {
+	notificationId := notificationUri[strings.LastIndex(notificationUri, "/")+1:]
+	assert.Contains(t, notificationUri, factory.EirCallbackResUriPrefix+"/nf-status-notify/")
+	assert.True(t, eirSelf.IsAmfNotificationId(notificationId))
+}
+
+func TestHeartbeatToNrf(t *testing.T) {
+	nrf := newFakeNrf()
+	app := setupNrfApp(t, nrf)
+	eirSelf := app.eirCtx
+	eirSelf.SetHeartBeatTimer(10 * time.Millisecond)
+	eirSelf.SetAmfSubscription("amf-status-0", time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.heartbeatToNrf(ctx)
+	}()
+
+	select {
+	case <-nrf.subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The EIR isn't registered again after the 404 of its heartbeat")
+	}
+	cancel()
+	<-done
+
+	nrf.mu.Lock()
+	defer nrf.mu.Unlock()
+	require.Len(t, nrf.patches, 2)
+	for _, patch := range nrf.patches {
+		require.Len(t, patch, 1)
+		assert.Equal(t, models.PatchOperation_REPLACE, patch[0].Op)
+		assert.Equal(t, "/nfStatus", patch[0].Path)
+		assert.Equal(t, string(models.NrfNfManagementNfStatus_REGISTERED), patch[0].Value)
+	}
+	// The second heartbeat waits the heartbeat timer of the first response
+	assert.GreaterOrEqual(t, nrf.patchedAt[1].Sub(nrf.patchedAt[0]), 900*time.Millisecond)
+
+	// The 404 registers the EIR again with the heartbeat timer of the registration
+	assert.Equal(t, 1, nrf.registrations)
+	assert.True(t, eirSelf.IsRegistered())
+	assert.Equal(t, 2*time.Second, eirSelf.HeartBeatTimer())
+	assert.Equal(t, testNfId, eirSelf.NfId())
+	// The subscription of the previous registration is replaced
+	assert.Equal(t, []string{"amf-status-0"}, nrf.removed)
+	require.Len(t, nrf.subscriptions, 1)
+	assertNotificationUri(t, eirSelf, nrf.subscriptions[0])
+	subscriptionId, _ := eirSelf.AmfSubscription()
+	assert.Equal(t, "amf-status-1", subscriptionId)
+}
+
+func TestRenewAmfPeers(t *testing.T) {
+	nrf := newFakeNrf()
+	validityTime := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	nrf.validityTime = &validityTime
+	app := setupNrfApp(t, nrf)
+	eirSelf := app.eirCtx
+	eirSelf.SetRegistered(true)
+
+	// The subscription isn't renewed long before its validity time
+	eirSelf.SetAmfSubscription("amf-status-0", time.Now().Add(time.Hour))
+	app.renewAmfPeers(time.Minute)
+	assert.Empty(t, nrf.removed)
+	assert.Empty(t, nrf.subscriptions)
+
+	// It's renewed when it expires within the next heartbeats
+	eirSelf.SetAmfSubscription("amf-status-0", time.Now().Add(time.Minute))
+	app.renewAmfPeers(time.Minute)
+	assert.Equal(t, []string{"amf-status-0"}, nrf.removed)
+	require.Len(t, nrf.subscriptions, 1)
+	assertNotificationUri(t, eirSelf, nrf.subscriptions[0])
+	subscriptionId, validity := eirSelf.AmfSubscription()
+	assert.Equal(t, "amf-status-1", subscriptionId)
+	assert.True(t, validityTime.Equal(validity))
+}
+
+func TestTerminateProcedure(t *testing.T) {
+	nrf := newFakeNrf()
+	app := setupNrfApp(t, nrf)
+	app.shutdownTracing = func(context.Context) error { return nil }
+	eirSelf := app.eirCtx
+
+	var nrfCtx context.Context
+	nrfCtx, app.nrfCancel = context.WithCancel(context.Background())
+	app.nrfWg.Add(1)
+	go app.keepRegisteredToNrf(nrfCtx)
+
+	select {
+	case <-nrf.subscribed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The EIR isn't registered")
+	}
+	app.terminateProcedure()
+
+	// The NRF goroutine is stopped before the subscription is removed and the EIR deregistered
+	nrf.mu.Lock()
+	defer nrf.mu.Unlock()
+	assert.Len(t, nrf.subscriptions, 1)
+	assert.Equal(t, []string{"amf-status-1"}, nrf.removed)
+	assert.Equal(t, 1, nrf.deregistered)
+	assert.False(t, eirSelf.IsRegistered())
+	subscriptionId, _ := eirSelf.AmfSubscription()
+	assert.Empty(t, subscriptionId)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+)
+
+// ReloadConfig reads, overrides and validates the configuration file again, then applies the logger, the DefaultStatus and the
+// OAuth2 settings live. The other changes are reported, they're applied on restart. The configuration has no
+// rate-limit settings, so none is reloaded.
+// An invalid configuration, or an OAuth2 key set which can't be loaded, is refused and the running one is kept.
+func (a *EirApp) ReloadConfig(cfgPath string, overrides ...string) error {
+	next, err := factory.ReadConfig(cfgPath, overrides...)
+	if err != nil {
+		return err
+	}
+
+	// The token verifier is built before any setting is applied, so its failure doesn't leave a partial reload
+	oauth2 := next.GetOAuth2()
+	oauth2Changed := !reflect.DeepEqual(oauth2, a.cfg.GetOAuth2())
+	var tokenVerifier eir_context.TokenVerifier
+	if oauth2Changed {
+		if tokenVerifier, err = eir_context.NewTokenVerifier(oauth2, a.eirCtx.NfId()); err != nil {
+			return fmt.Errorf("the OAuth2 verification can't be reloaded: %w", err)
+		}
+	}
+
+	a.SetLogEnable(next.GetLogEnable())
+	a.SetLogLevel(next.GetLogLevel())
+	a.SetReportCaller(next.GetLogReportCaller())
+
+	if defaultStatus := next.GetDefaultStatus(); defaultStatus != a.cfg.GetDefaultStatus() {
+		a.cfg.SetDefaultStatus(defaultStatus)
+		logger.CfgLog.Infof("Default status is set to [%s]", defaultStatus)
+	}
+
+	if oauth2Changed {
+		a.cfg.SetOAuth2(oauth2)
+		a.eirCtx.SetTokenVerifier(tokenVerifier)
+		logger.CfgLog.Infof("OAuth2 verification is reloaded")
+	}
+
+	// The live settings are already equal
+	for _, field := range changedFields("", reflect.ValueOf(a.cfg.Configuration), reflect.ValueOf(next.Configuration)) {
+		logger.CfgLog.Warnf("The [%s] change requires a restart", field)
+	}
+	logger.CfgLog.Infof("The configuration [%s] is reloaded", cfgPath)
+	return nil
+}
+
+// changedFields lists the YAML paths of the fields which differ, the structs are compared field by field
+func changedFields(prefix string, current reflect.Value, next reflect.Value) []string {
+	if current.Kind() == reflect.Pointer && !current.IsNil() && !next.IsNil() {
+		current, next = current.Elem(), next.Elem()
+	}
+	if current.Kind() != reflect.Struct {
+		if reflect.DeepEqual(current.Interface(), next.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var changed []string
+	for i := 0; i < current.NumField(); i++ {
+		field := current.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		changed = append(changed, changedFields(name, current.Field(i), next.Field(i))...)
+	}
+	return changed
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	eir_context "github.com/adjivas/eir/internal/context"
+	"github.com/adjivas/eir/internal/logger"
+	"github.com/adjivas/eir/pkg/factory"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, path string, level string, defaultStatus string, port string) {
+	content := []byte(`info:
+  version: 1.1.0
+  description: EIR initial local configuration
+
+logger:
+  enable: true
+  level: ` + level + `
+
+configuration:
+  defaultStatus: ` + defaultStatus + `
+  dbConnectorType: memory
+  nrfUri: http://127.0.0.10:8000
+  nrfCertPem: cert/nrf.pem
+  sbi:
+    scheme: http
+    registerIP: "127.0.0.13"
+    bindingIP: "127.0.0.13"
+    port: ` + port)
+	require.Nil(t, os.WriteFile(path, content, 0o600))
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eircfg.yaml")
+	writeConfigFile(t, path, "info", "BLACKLISTED", "8131")
+	cfg, err := factory.ReadConfig(path)
+	require.Nil(t, err)
+	app := &EirApp{cfg: cfg, eirCtx: eir_context.GetSelf()}
+	level := logger.Log.GetLevel()
+	t.Cleanup(func() { logger.Log.SetLevel(level) })
+
+	writeConfigFile(t, path, "debug", "WHITELISTED", "8132")
+	require.Nil(t, app.ReloadConfig(path))
+	assert.Equal(t, logrus.DebugLevel, logger.Log.GetLevel())
+	assert.Equal(t, "debug", cfg.GetLogLevel())
+	assert.Equal(t, "WHITELISTED", cfg.GetDefaultStatus())
+	// The port is applied on restart
+	assert.Equal(t, 8131, cfg.Configuration.Sbi.Port)
+
+	// An invalid configuration is refused without changing the running one
+	writeConfigFile(t, path, "warn", "UNKNOWN", "8131")
+	assert.NotNil(t, app.ReloadConfig(path))
+	assert.Equal(t, logrus.DebugLevel, logger.Log.GetLevel())
+	assert.Equal(t, "WHITELISTED", cfg.GetDefaultStatus())
+}
+
+func TestReloadConfigMalformedJwks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eircfg.yaml")
+	writeConfigFile(t, path, "info", "BLACKLISTED", "8131")
+	cfg, err := factory.ReadConfig(path)
+	require.Nil(t, err)
+	app := &EirApp{cfg: cfg, eirCtx: eir_context.GetSelf()}
+	level := logger.Log.GetLevel()
+	t.Cleanup(func() { logger.Log.SetLevel(level) })
+
+	// The key set is checked before the logger and the DefaultStatus are applied
+	writeConfigFile(t, path, "debug", "WHITELISTED", "8131")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.Nil(t, err)
+	_, err = file.WriteString("\n  oauth2:\n    jwks: '{'\n")
+	require.Nil(t, err)
+	require.Nil(t, file.Close())
+
+	err = app.ReloadConfig(path)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the inline JWKS is malformed")
+	assert.Equal(t, level, logger.Log.GetLevel())
+	assert.Equal(t, "BLACKLISTED", cfg.GetDefaultStatus())
+	assert.Nil(t, cfg.GetOAuth2())
+	assert.Nil(t, app.eirCtx.GetTokenVerifier())
+}
+
+func TestChangedFields(t *testing.T) {
+	current := &factory.Configuration{
+		DefaultStatus: "BLACKLISTED",
+		Sbi:           &factory.Sbi{BindingIP: "127.0.0.13", Port: 8131},
+	}
+	next := &factory.Configuration{
+		DefaultStatus: "BLACKLISTED",
+		Sbi:           &factory.Sbi{BindingIP: "127.0.0.14", Port: 8132},
+		Metrics:       &factory.Metrics{BindingIP: "127.0.0.14", Port: 9090},
+	}
+
+	assert.Equal(t, []string{"sbi.bindingIP", "sbi.port", "metrics"},
+		changedFields("", reflect.ValueOf(current), reflect.ValueOf(next)))
+}